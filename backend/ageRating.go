@@ -0,0 +1,253 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// The rating systems an AgeRating can belong to. Ratings written as a plain
+// age, such as "12+", have no system.
+const (
+	RatingSystemPEGI = "PEGI"
+	RatingSystemESRB = "ESRB"
+)
+
+// maxGenericAge is the oldest age accepted in a plain "N+" rating
+const maxGenericAge = 99
+
+// pegiAges are the ratings PEGI awards
+var pegiAges = []int{3, 7, 12, 16, 18}
+
+// esrbAges maps each ESRB rating to the minimum age it recommends. Rating
+// Pending is treated as adults only until a rating is awarded.
+var esrbAges = []struct {
+	label  string
+	minAge int
+}{
+	{"EC", 3},
+	{"E", 6},
+	{"E10+", 10},
+	{"T", 13},
+	{"M", 17},
+	{"AO", 18},
+	{"RP", 18},
+}
+
+// ageRatingRegexp matches every rating ParseAgeRating accepts. Submatch 1 is
+// a PEGI age, 2 an ESRB rating and 3 a plain age.
+var ageRatingRegexp = regexp.MustCompile(`^\s*(?i:PEGI\s*(\d+)|(?:ESRB\s*)?(EC|E10\+|E|T|M|AO|RP)|(\d{1,2})\+?)\s*$`)
+
+// AgeRating is a parsed game age rating such as "PEGI 12", "ESRB T" or "16+".
+// It is encoded as the label it was parsed from.
+type AgeRating struct {
+	System string
+	Label  string
+	MinAge int
+	// Valid is false for missing ratings and for ratings which couldn't be
+	// parsed when read from the database.
+	Valid bool
+}
+
+// ParseAgeRating parses a PEGI or ESRB rating, with or without the name of
+// the system, or a plain minimum age written as "N" or "N+".
+func ParseAgeRating(s string) (rating AgeRating, err error) {
+	match := ageRatingRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return rating, fmt.Errorf("Invalid age rating %q, expected a PEGI or ESRB rating or an age such as 12+", s)
+	}
+
+	rating.Label = strings.TrimSpace(s)
+	rating.Valid = true
+
+	switch {
+	case match[1] != "":
+		rating.System = RatingSystemPEGI
+		rating.MinAge, _ = strconv.Atoi(match[1])
+		if !containsInt(pegiAges, rating.MinAge) {
+			return AgeRating{}, fmt.Errorf("Invalid PEGI rating %q, PEGI ratings are 3, 7, 12, 16 and 18", s)
+		}
+	case match[2] != "":
+		rating.System = RatingSystemESRB
+		for _, esrb := range esrbAges {
+			if strings.EqualFold(esrb.label, match[2]) {
+				rating.MinAge = esrb.minAge
+			}
+		}
+	default:
+		rating.MinAge, _ = strconv.Atoi(match[3])
+	}
+
+	return rating, nil
+}
+
+// MustParseAgeRating is like ParseAgeRating but panics if s is invalid. It
+// simplifies declaring games in code.
+func MustParseAgeRating(s string) AgeRating {
+	rating, err := ParseAgeRating(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return rating
+}
+
+// String returns the label the rating was parsed from
+func (rating AgeRating) String() string {
+	return rating.Label
+}
+
+// SuitableFor reports whether a game with the rating may be shown to someone
+// of the given age. Missing and invalid ratings are never suitable, as there
+// is no way to tell who the game is for.
+func (rating AgeRating) SuitableFor(age int) bool {
+	return rating.Valid && rating.MinAge <= age
+}
+
+// MarshalJSON encodes the rating as its label
+func (rating AgeRating) MarshalJSON() ([]byte, error) {
+	return json.Marshal(rating.Label)
+}
+
+// UnmarshalJSON parses a rating from its label, validating it so that invalid
+// ratings are rejected when games are ingested. An empty label is left unset.
+func (rating *AgeRating) UnmarshalJSON(data []byte) error {
+	var label string
+	if err := json.Unmarshal(data, &label); err != nil {
+		return fmt.Errorf("Error decoding age rating: %v", err)
+	}
+
+	if label == "" {
+		*rating = AgeRating{}
+		return nil
+	}
+
+	parsed, err := ParseAgeRating(label)
+	if err != nil {
+		return err
+	}
+
+	*rating = parsed
+	return nil
+}
+
+// MarshalBSONValue stores the rating as its label
+func (rating AgeRating) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(rating.Label)
+}
+
+// UnmarshalBSONValue parses a rating read from mongoDB. Ratings stored before
+// validation was added may be invalid, they are kept with a warning rather
+// than failing to read the whole game.
+func (rating *AgeRating) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	v := bson.RawValue{
+		Type:  t,
+		Value: data,
+	}
+
+	label, ok := v.StringValueOK()
+	if !ok {
+		log.WithFields(log.Fields{
+			"Type": t,
+			"Data": data,
+		}).Warnf("Error decoding age rating from database.")
+
+		return nil
+	}
+
+	parsed, err := ParseAgeRating(label)
+	if err != nil {
+		log.Warnf("Invalid age rating in database: %v", err)
+		*rating = AgeRating{Label: label}
+		return nil
+	}
+
+	*rating = parsed
+	return nil
+}
+
+// ageRatingPattern builds a regular expression matching the labels of every
+// valid rating suitable for age, using the same grammar as ageRatingRegexp,
+// so that mongoDB can filter games by rating.
+func ageRatingPattern(age int) string {
+	pegi := make([]string, 0)
+	for _, minAge := range pegiAges {
+		if minAge <= age {
+			pegi = append(pegi, strconv.Itoa(minAge))
+		}
+	}
+
+	esrb := make([]string, 0)
+	for _, rating := range esrbAges {
+		if rating.minAge <= age {
+			esrb = append(esrb, regexp.QuoteMeta(rating.label))
+		}
+	}
+
+	plain := make([]string, 0)
+	for minAge := 0; minAge <= age && minAge <= maxGenericAge; minAge++ {
+		if minAge < 10 {
+			plain = append(plain, "0"+strconv.Itoa(minAge))
+		}
+		plain = append(plain, strconv.Itoa(minAge))
+	}
+
+	alternatives := make([]string, 0, 3)
+	if len(pegi) > 0 {
+		alternatives = append(alternatives, `PEGI\s*(?:`+strings.Join(pegi, "|")+`)`)
+	}
+	if len(esrb) > 0 {
+		// Longer labels first so E10+ isn't matched as E
+		for i, j := 0, len(esrb)-1; i < j; i, j = i+1, j-1 {
+			esrb[i], esrb[j] = esrb[j], esrb[i]
+		}
+		alternatives = append(alternatives, `(?:ESRB\s*)?(?:`+strings.Join(esrb, "|")+`)`)
+	}
+	if len(plain) > 0 {
+		alternatives = append(alternatives, `(?:`+strings.Join(plain, "|")+`)\+?`)
+	}
+	if len(alternatives) == 0 {
+		// Nothing is suitable, match no label at all
+		return `$^`
+	}
+
+	return `^\s*(?:` + strings.Join(alternatives, "|") + `)\s*$`
+}
+
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+type maxAgeKey struct{}
+
+// WithMaxAge returns a copy of ctx which limits the games seen by data
+// sources to those suitable for age. Hidden games are left out of reports and
+// can't be fetched.
+func WithMaxAge(ctx context.Context, age int) context.Context {
+	return context.WithValue(ctx, maxAgeKey{}, age)
+}
+
+// MaxAge returns the age set by WithMaxAge, if there is one
+func MaxAge(ctx context.Context) (age int, ok bool) {
+	age, ok = ctx.Value(maxAgeKey{}).(int)
+	return age, ok
+}
+
+// visible reports whether game should be seen by operations using ctx
+func visible(ctx context.Context, game Game) bool {
+	age, ok := MaxAge(ctx)
+	return !ok || game.AgeRating.SuitableFor(age)
+}
@@ -0,0 +1,170 @@
+package backend
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SectionAgeRatings is the name of the age rating report section.
+const SectionAgeRatings = "age_ratings"
+
+// UnratedBand is the band of games with a missing or invalid age rating
+const UnratedBand = "unrated"
+
+// ageBands are the minimum ages games are grouped by, in the style of PEGI.
+// Ratings from other systems fall in the first band at or above their age.
+var ageBands = []int{3, 7, 12, 16, 18}
+
+// AgeRatingReport breaks games down by the band their age rating falls in.
+// Unrated lists the games without a valid rating so they can be fixed.
+type AgeRatingReport struct {
+	Bands   []AgeRatingBand `json:"bands"`
+	Unrated []string        `json:"unrated"`
+}
+
+// AgeRatingBand totals the games whose rating falls in a band. MinAge is
+// zero for the unrated band.
+type AgeRatingBand struct {
+	Band         string `json:"band"`
+	MinAge       int    `json:"min_age"`
+	Games        int    `json:"games"`
+	GameLikes    int    `json:"game_likes"`
+	Comments     int    `json:"comments"`
+	CommentLikes int    `json:"comment_likes"`
+}
+
+// ageBand returns the band a rating falls in
+func ageBand(rating AgeRating) (name string, minAge int) {
+	if !rating.Valid {
+		return UnratedBand, 0
+	}
+
+	for _, band := range ageBands {
+		if rating.MinAge <= band {
+			return bandName(band), band
+		}
+	}
+
+	last := ageBands[len(ageBands)-1]
+	return bandName(last), last
+}
+
+func bandName(minAge int) string {
+	return strconv.Itoa(minAge) + "+"
+}
+
+// ageRatingAccumulator totals games by rating band as they are added to it
+type ageRatingAccumulator struct {
+	bands   map[string]*AgeRatingBand
+	unrated []string
+}
+
+func newAgeRatingAcc() *ageRatingAccumulator {
+	return &ageRatingAccumulator{
+		bands:   make(map[string]*AgeRatingBand),
+		unrated: make([]string, 0),
+	}
+}
+
+func (acc *ageRatingAccumulator) add(title string, rating AgeRating, gameLikes, comments, commentLikes int) {
+	name, minAge := ageBand(rating)
+	band, ok := acc.bands[name]
+	if !ok {
+		band = &AgeRatingBand{Band: name, MinAge: minAge}
+		acc.bands[name] = band
+	}
+
+	band.Games++
+	band.GameLikes += gameLikes
+	band.Comments += comments
+	band.CommentLikes += commentLikes
+
+	if !rating.Valid {
+		acc.unrated = append(acc.unrated, title)
+	}
+}
+
+func (acc *ageRatingAccumulator) Add(game Game) {
+	commentLikes := 0
+	for _, comment := range game.Comments {
+		commentLikes += comment.Like
+	}
+
+	acc.add(game.Title, game.AgeRating, game.Likes, len(game.Comments), commentLikes)
+}
+
+// Result lists the bands youngest first, with the unrated band last
+func (acc *ageRatingAccumulator) Result() interface{} {
+	report := AgeRatingReport{
+		Bands:   make([]AgeRatingBand, 0, len(acc.bands)),
+		Unrated: acc.unrated,
+	}
+
+	for _, band := range acc.bands {
+		report.Bands = append(report.Bands, *band)
+	}
+
+	sort.Slice(report.Bands, func(i, j int) bool {
+		a, b := report.Bands[i], report.Bands[j]
+		if (a.Band == UnratedBand) != (b.Band == UnratedBand) {
+			return b.Band == UnratedBand
+		}
+		return a.MinAge < b.MinAge
+	})
+	sort.Strings(report.Unrated)
+
+	return report
+}
+
+type ageRatingResult struct {
+	Title        string    `bson:"title"`
+	AgeRating    AgeRating `bson:"age_rating"`
+	Likes        int       `bson:"likes"`
+	Comments     int       `bson:"comments"`
+	CommentLikes int       `bson:"comment_likes"`
+}
+
+// ageRatingPipeline totals the comments and their likes for each game. The
+// ratings are parsed and grouped into bands as the games are decoded.
+func ageRatingPipeline() []bson.D {
+	projectTotals := bson.D{
+		{
+			"$project", bson.D{
+				{"title", 1},
+				{"age_rating", 1},
+				{"likes", 1},
+				{"comments", bson.D{{"$size", bson.D{{"$ifNull", bson.A{"$comments", bson.A{}}}}}}},
+				{"comment_likes", bson.D{{"$sum", "$comments.like"}}},
+			},
+		},
+	}
+
+	return []bson.D{
+		projectTotals,
+	}
+}
+
+func init() {
+	RegisterReportSection(ReportSection{
+		Name:     SectionAgeRatings,
+		Pipeline: ageRatingPipeline,
+		Decode: func(ctx context.Context, cur SectionCursor) (interface{}, error) {
+			acc := newAgeRatingAcc()
+			for cur.Next(ctx) {
+				var res ageRatingResult
+				if err := cur.Decode(&res); err != nil {
+					return nil, err
+				}
+				acc.add(res.Title, res.AgeRating, res.Likes, res.Comments, res.CommentLikes)
+			}
+
+			return acc.Result(), cur.Err()
+		},
+		Accumulator: func() SectionAccumulator {
+			return newAgeRatingAcc()
+		},
+	})
+}
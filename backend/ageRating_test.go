@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAgeRating(t *testing.T) {
+	tests := []struct {
+		label   string
+		want    AgeRating
+		wantErr bool
+	}{
+		{label: "PEGI 12", want: AgeRating{System: RatingSystemPEGI, Label: "PEGI 12", MinAge: 12, Valid: true}},
+		{label: "pegi18", want: AgeRating{System: RatingSystemPEGI, Label: "pegi18", MinAge: 18, Valid: true}},
+		{label: "ESRB E10+", want: AgeRating{System: RatingSystemESRB, Label: "ESRB E10+", MinAge: 10, Valid: true}},
+		{label: " M ", want: AgeRating{System: RatingSystemESRB, Label: "M", MinAge: 17, Valid: true}},
+		{label: "16+", want: AgeRating{Label: "16+", MinAge: 16, Valid: true}},
+		{label: "6", want: AgeRating{Label: "6", MinAge: 6, Valid: true}},
+		{label: "PEGI 13", wantErr: true},
+		{label: "ESRB 12", wantErr: true},
+		{label: "100+", wantErr: true},
+		{label: "everyone", wantErr: true},
+		{label: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			got, err := ParseAgeRating(tt.label)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAgeRating_UnmarshalJSON(t *testing.T) {
+	var game Game
+	assert.NoError(t, json.Unmarshal([]byte(`{"age_rating": "PEGI 7"}`), &game))
+	assert.Equal(t, 7, game.AgeRating.MinAge)
+
+	encoded, err := json.Marshal(game.AgeRating)
+	assert.NoError(t, err)
+	assert.Equal(t, `"PEGI 7"`, string(encoded), "Ratings should be encoded as their label")
+
+	err = json.Unmarshal([]byte(`{"age_rating": "PEGI 8"}`), &game)
+	assert.Error(t, err, "Invalid ratings should be rejected on ingest")
+}
+
+// The pattern used by mongoDB must agree with SuitableFor for every label
+func Test_ageRatingPattern(t *testing.T) {
+	labels := []string{
+		"PEGI 3", "pegi 7", "PEGI12", "PEGI 16", "PEGI 18", "PEGI 13",
+		"EC", "E", "esrb e10+", "ESRB T", "M", "AO", "RP",
+		"0", "3+", "07", "7+", "10", "12+", "017", "18+", "42+",
+		"", "unknown", "E10", "+",
+	}
+
+	for age := 0; age <= 21; age++ {
+		pattern := regexp.MustCompile("(?i)" + ageRatingPattern(age))
+		for _, label := range labels {
+			rating, _ := ParseAgeRating(label)
+			assert.Equal(t, rating.SuitableFor(age), pattern.MatchString(label),
+				"Pattern for age %d disagrees about %q", age, label)
+		}
+	}
+}
+
+func TestMemoryDataSource_maxAge(t *testing.T) {
+	mem := NewMemoryDataSource(map[string]Game{
+		"1": {Title: "Kids", AgeRating: MustParseAgeRating("PEGI 3"), Comments: []Comment{{User: "a", Like: 1}}},
+		"2": {Title: "Teens", AgeRating: MustParseAgeRating("ESRB T"), Comments: []Comment{{User: "b", Like: 2}}},
+		"3": {Title: "Unrated", Comments: []Comment{{User: "c", Like: 3}}},
+	})
+	ctx := WithMaxAge(context.Background(), 12)
+
+	_, err := mem.Game(ctx, "1")
+	assert.NoError(t, err)
+	_, err = mem.Game(ctx, "2")
+	assert.Error(t, err, "Games above the age limit should be hidden")
+	_, err = mem.Game(ctx, "3")
+	assert.Error(t, err, "Unrated games should be hidden when there is an age limit")
+
+	report, err := mem.Report(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "Kids", report.HighestRatedGame)
+	assert.Len(t, report.AverageLikesPerGame, 1)
+
+	sections, err := mem.ReportSections(context.Background(), []string{SectionAgeRatings})
+	assert.NoError(t, err)
+	assert.Equal(t, AgeRatingReport{
+		Bands: []AgeRatingBand{
+			{Band: "3+", MinAge: 3, Games: 1, Comments: 1, CommentLikes: 1},
+			{Band: "16+", MinAge: 16, Games: 1, Comments: 1, CommentLikes: 2},
+			{Band: UnratedBand, Games: 1, Comments: 1, CommentLikes: 3},
+		},
+		Unrated: []string{"Unrated"},
+	}, sections[SectionAgeRatings])
+}
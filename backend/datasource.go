@@ -1,10 +1,37 @@
 package backend
 
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrGameNotFound is returned by a GameDataSource when there is no visible
+// game with the requested id. Other errors are failures of the data source.
+var ErrGameNotFound = errors.New("Game not found")
+
 // GameDataSource represents any type which can provide data for the games
-// service
+// service. Implementations should stop work and return the context's error
+// once ctx is done.
 type GameDataSource interface {
-	Game(id string) (Game, error)
-	Report() (Report, error)
+	Game(ctx context.Context, id string) (Game, error)
+	Report(ctx context.Context) (Report, error)
+	// StreamReport calls row with the statistics for each game, most liked
+	// first as in Report.AverageLikesPerGame, and returns the remainder of the
+	// report, without AverageLikesPerGame, once every game has been seen. It
+	// stops and returns the error if row fails.
+	StreamReport(ctx context.Context, row func(GameAverageLikes) error) (Report, error)
+	// ReportSections computes only the named report sections, see
+	// RegisterReportSection. The result is keyed by section name.
+	ReportSections(ctx context.Context, names []string) (map[string]interface{}, error)
+	TimeSeries(ctx context.Context, interval Interval) (TimeSeries, error)
+	// Trending ranks the games with comments in a recent window, see
+	// TrendingOptions.
+	Trending(ctx context.Context, opts TrendingOptions) (Trending, error)
+	GameStats(ctx context.Context, id string, top int) (GameStats, error)
+	// SimilarGames lists at most limit games most like the game with the given
+	// id, explaining why each is similar. A limit of zero lists them all.
+	SimilarGames(ctx context.Context, id string, limit int) ([]SimilarGame, error)
 }
 
 // ServiceDataSource represents any type which can provide data for the entire
@@ -12,3 +39,42 @@ type GameDataSource interface {
 type ServiceDataSource interface {
 	GameDataSource
 }
+
+// ProgressFunc is called as a long running operation, such as creating a
+// report, progresses with the amount of work done so far out of total.
+type ProgressFunc func(done, total int)
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx which reports the progress of operations
+// performed with it to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress calls the ProgressFunc attached to ctx, if there is one.
+func reportProgress(ctx context.Context, done, total int) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok {
+		fn(done, total)
+	}
+}
+
+// PipelineObserver is called after each aggregation pipeline a data source
+// runs with the pipeline's name, how long it took and the error, if any.
+type PipelineObserver func(pipeline string, duration time.Duration, err error)
+
+type pipelineObserverKey struct{}
+
+// WithPipelineObserver returns a copy of ctx which reports the aggregation
+// pipelines run with it to fn.
+func WithPipelineObserver(ctx context.Context, fn PipelineObserver) context.Context {
+	return context.WithValue(ctx, pipelineObserverKey{}, fn)
+}
+
+// observePipeline calls the PipelineObserver attached to ctx, if there is one,
+// with the time since start.
+func observePipeline(ctx context.Context, pipeline string, start time.Time, err error) {
+	if fn, ok := ctx.Value(pipelineObserverKey{}).(PipelineObserver); ok {
+		fn(pipeline, time.Since(start), err)
+	}
+}
@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotFileFormat is the time format used to name snapshot files. It sorts
+// lexically in the same order as the times it represents.
+const snapshotFileFormat = "20060102T150405.000000000Z"
+
+// FileReportStore implements backend.ReportStore by saving each snapshot as a
+// JSON file in a directory. It allows report history to be kept for data
+// sources that don't have their own storage.
+type FileReportStore struct {
+	dir string
+}
+
+// NewFileReportStore creates a file report store in dir, creating the
+// directory if it doesn't exist
+func NewFileReportStore(dir string) (*FileReportStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileReportStore{dir: dir}, nil
+}
+
+// SaveReport writes the snapshot to a new file named after the time it was
+// taken
+func (store *FileReportStore) SaveReport(ctx context.Context, snapshot ReportSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	name := "report-" + snapshot.TakenAt.UTC().Format(snapshotFileFormat) + ".json"
+
+	// Write to a temporary file first so readers never see a partial snapshot
+	tmp, err := ioutil.TempFile(store.dir, ".report-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(store.dir, name))
+}
+
+// ReportHistory reads the snapshots taken between from and to inclusive
+func (store *FileReportStore) ReportHistory(ctx context.Context, from, to time.Time) ([]ReportSnapshot, error) {
+	times, err := store.snapshotTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ReportSnapshot, 0)
+	for _, t := range times {
+		if t.Before(from) || t.After(to) {
+			continue
+		}
+
+		snapshot, err := store.read(t)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, snapshot)
+	}
+
+	return history, nil
+}
+
+// ReportAt reads the latest snapshot taken at or before t
+func (store *FileReportStore) ReportAt(ctx context.Context, t time.Time) (snapshot ReportSnapshot, err error) {
+	times, err := store.snapshotTimes()
+	if err != nil {
+		return snapshot, err
+	}
+
+	for i := len(times) - 1; i >= 0; i-- {
+		if !times[i].After(t) {
+			return store.read(times[i])
+		}
+	}
+
+	return snapshot, ErrSnapshotNotFound
+}
+
+// snapshotTimes lists the times of the stored snapshots, oldest first
+func (store *FileReportStore) snapshotTimes() ([]time.Time, error) {
+	files, err := ioutil.ReadDir(store.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	times := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, "report-") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, "report-"), ".json")
+		t, err := time.Parse(snapshotFileFormat, stamp)
+		if err != nil {
+			continue
+		}
+		times = append(times, t)
+	}
+
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+
+	return times, nil
+}
+
+func (store *FileReportStore) read(t time.Time) (snapshot ReportSnapshot, err error) {
+	name := "report-" + t.UTC().Format(snapshotFileFormat) + ".json"
+	data, err := ioutil.ReadFile(filepath.Join(store.dir, name))
+	if err != nil {
+		return snapshot, err
+	}
+
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("Error decoding report snapshot %s: %v", name, err)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,33 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// HealthChecker is implemented by data sources and report stores which can
+// check that the dependencies they need, such as a database, are available.
+type HealthChecker interface {
+	// CheckHealth returns an error if the dependency can't currently be used.
+	CheckHealth(ctx context.Context) error
+}
+
+// CheckHealth pings the database
+func (mongo *MongoDataSource) CheckHealth(ctx context.Context) error {
+	return mongo.client.Ping(ctx, nil)
+}
+
+// CheckHealth checks the store's directory still exists
+func (store *FileReportStore) CheckHealth(ctx context.Context) error {
+	info, err := os.Stat(store.dir)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", store.dir)
+	}
+
+	return nil
+}
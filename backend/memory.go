@@ -0,0 +1,190 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// MemoryDataSource implements backend.ServiceDataSource using games held in
+// memory. It is useful for tests and small data sets which don't warrant a
+// database.
+type MemoryDataSource struct {
+	games map[string]Game
+	ids   []string
+}
+
+// NewMemoryDataSource creates a new in memory data source serving the given
+// games, keyed by their id. The ID of each game is set from its key.
+func NewMemoryDataSource(games map[string]Game) *MemoryDataSource {
+	ids := make([]string, 0, len(games))
+	byID := make(map[string]Game, len(games))
+	for id, game := range games {
+		game.ID = id
+		byID[id] = game
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return &MemoryDataSource{
+		games: byID,
+		ids:   ids,
+	}
+}
+
+// Game retrieves information for a game with the given id. Games hidden by
+// WithMaxAge aren't found.
+func (mem *MemoryDataSource) Game(ctx context.Context, id string) (game Game, err error) {
+	game, ok := mem.games[id]
+	if !ok || !visible(ctx, game) {
+		return game, fmt.Errorf("%w: %s", ErrGameNotFound, id)
+	}
+
+	return game, nil
+}
+
+// GameStats calculates statistics for the game with the given id, listing at
+// most top commenters
+func (mem *MemoryDataSource) GameStats(ctx context.Context, id string, top int) (stats GameStats, err error) {
+	game, err := mem.Game(ctx, id)
+	if err != nil {
+		return stats, err
+	}
+
+	return newGameStats(game, top), nil
+}
+
+// Report creates a report from the stored game data. Progress is reported as
+// each game is analysed, see WithProgress.
+func (mem *MemoryDataSource) Report(ctx context.Context) (report Report, err error) {
+	games := make([]GameAverageLikes, 0, len(mem.ids))
+	report, err = mem.StreamReport(ctx, func(stats GameAverageLikes) error {
+		games = append(games, stats)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	report.AverageLikesPerGame = games
+
+	return report, nil
+}
+
+// StreamReport calls row with the statistics for each game, most liked first
+// as for MongoDataSource. The games are in memory already, so every game is
+// processed before the first row. The leaders of the report are returned once
+// every row has been written.
+func (mem *MemoryDataSource) StreamReport(ctx context.Context, row func(GameAverageLikes) error) (summary Report, err error) {
+	acc := newReportAcc()
+	games := make([]GameAverageLikes, 0, len(mem.ids))
+	for i, id := range mem.ids {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+
+		if game := mem.games[id]; visible(ctx, game) {
+			games = append(games, acc.processGame(game))
+		}
+		reportProgress(ctx, i+1, len(mem.ids))
+	}
+
+	sortByTotalLikes(games)
+	for _, stats := range games {
+		if err := row(stats); err != nil {
+			return summary, err
+		}
+	}
+
+	return acc.summary(), nil
+}
+
+// SimilarGames compares every other game to the game with the given id
+func (mem *MemoryDataSource) SimilarGames(ctx context.Context, id string, limit int) ([]SimilarGame, error) {
+	game, err := mem.Game(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	acc := newSimilarAcc(game, limit)
+	for _, candidateID := range mem.ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if candidate := mem.games[candidateID]; visible(ctx, candidate) {
+			acc.processGame(candidate)
+		}
+	}
+
+	return acc.result(), nil
+}
+
+// ReportSections computes the named report sections in a single pass over the
+// stored games
+func (mem *MemoryDataSource) ReportSections(ctx context.Context, names []string) (map[string]interface{}, error) {
+	sections, err := lookupReportSections(names)
+	if err != nil {
+		return nil, err
+	}
+
+	accumulators := make([]SectionAccumulator, len(sections))
+	for i, section := range sections {
+		if section.Accumulator == nil {
+			return nil, unsupportedSectionError(section.Name)
+		}
+		accumulators[i] = section.Accumulator()
+	}
+
+	for _, id := range mem.ids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		game := mem.games[id]
+		if !visible(ctx, game) {
+			continue
+		}
+
+		for _, acc := range accumulators {
+			acc.Add(game)
+		}
+	}
+
+	results := make(map[string]interface{}, len(sections))
+	for i, section := range sections {
+		results[section.Name] = accumulators[i].Result()
+	}
+
+	return results, nil
+}
+
+// TimeSeries groups the comments on every game into buckets of the given
+// interval
+func (mem *MemoryDataSource) TimeSeries(ctx context.Context, interval Interval) (series TimeSeries, err error) {
+	acc := newTimeSeriesAcc(interval)
+	for _, id := range mem.ids {
+		if game := mem.games[id]; visible(ctx, game) {
+			acc.processGame(game)
+		}
+	}
+
+	return acc.timeSeries(), nil
+}
+
+// Trending ranks games by the decayed score of the comments made on them
+// within the window
+func (mem *MemoryDataSource) Trending(ctx context.Context, opts TrendingOptions) (trending Trending, err error) {
+	acc := newTrendingAcc(opts)
+	for _, id := range mem.ids {
+		if err := ctx.Err(); err != nil {
+			return trending, err
+		}
+
+		if game := mem.games[id]; visible(ctx, game) {
+			acc.processGame(game)
+		}
+	}
+
+	return acc.trending(), nil
+}
@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var memoryGames = map[string]Game{
+	"1": {
+		Title: "Quiet",
+		Comments: []Comment{
+			{User: "a", Like: 1},
+		},
+	},
+	"2": {
+		Title: "Loud",
+		Comments: []Comment{
+			{User: "b", Like: 4, DateCreated: EpochToReadable(time.Unix(1600000000, 0))},
+			{User: "b", Like: 2, DateCreated: EpochToReadable(time.Unix(1500000000, 0))},
+		},
+	},
+	"3": {Title: "Silent"},
+}
+
+func TestMemoryDataSource_Report(t *testing.T) {
+	mem := NewMemoryDataSource(memoryGames)
+
+	report, err := mem.Report(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "b", report.UserWithMostComments)
+	assert.Equal(t, "Loud", report.HighestRatedGame)
+	assert.Equal(t, time.Unix(1600000000, 0), report.NewestComment, "Report should know when the newest comment was made")
+
+	titles := make([]string, 0)
+	for _, stats := range report.AverageLikesPerGame {
+		titles = append(titles, stats.Title)
+	}
+	assert.Equal(t, []string{"Loud", "Quiet", "Silent"}, titles, "Games should be ordered by likes")
+}
+
+func TestMemoryDataSource_StreamReport(t *testing.T) {
+	mem := NewMemoryDataSource(memoryGames)
+
+	titles := make([]string, 0)
+	summary, err := mem.StreamReport(context.Background(), func(stats GameAverageLikes) error {
+		titles = append(titles, stats.Title)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Loud", "Quiet", "Silent"}, titles, "Games should be streamed most liked first")
+	assert.Equal(t, Report{UserWithMostComments: "b", HighestRatedGame: "Loud", NewestComment: time.Unix(1600000000, 0)}, summary)
+
+	stop := errors.New("stop")
+	_, err = mem.StreamReport(context.Background(), func(stats GameAverageLikes) error {
+		return stop
+	})
+	assert.Equal(t, stop, err, "Row errors should stop the stream")
+}
+
+func TestMemoryDataSource_Game(t *testing.T) {
+	mem := NewMemoryDataSource(memoryGames)
+
+	game, err := mem.Game(context.Background(), "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Quiet", game.Title)
+
+	_, err = mem.Game(context.Background(), "4")
+	assert.True(t, errors.Is(err, ErrGameNotFound), "Missing games should be ErrGameNotFound")
+
+	_, err = mem.GameStats(context.Background(), "4", 1)
+	assert.True(t, errors.Is(err, ErrGameNotFound), "Missing games should be ErrGameNotFound")
+}
@@ -14,21 +14,40 @@ import (
 // Game is a container for game data.
 // it also implements json.Marshaller so it can easily be encoded into json.
 type Game struct {
+	// ID identifies the game in URLs, so isn't part of its JSON
+	ID          string    `json:"-" bson:"id"`
 	Title       string    `json:"title" bson:"title"`
 	Description string    `json:"description"`
 	By          string    `json:"by"`
 	Platform    []string  `json:"platform"`
-	AgeRating   string    `json:"age_rating" bson:"age_rating"`
+	AgeRating   AgeRating `json:"age_rating" bson:"age_rating"`
 	Likes       int       `json:"likes"`
 	Comments    []Comment `json:"comments"`
 }
 
+// NewestComment returns when the newest comment on the game was made, or the
+// zero time if it has no comments
+func (game Game) NewestComment() time.Time {
+	var newest time.Time
+	for _, comment := range game.Comments {
+		if created := time.Time(comment.DateCreated); created.After(newest) {
+			newest = created
+		}
+	}
+
+	return newest
+}
+
+// commentDateField is the field Comment.DateCreated is stored in, for use in
+// aggregation pipelines
+const commentDateField = "datecreated"
+
 // Comment is a container for comment data.
 // It implements json.Marshaller so it can easily be encoded into json.
 type Comment struct {
 	User        string          `json:"user"`
 	Message     string          `json:"message"`
-	DateCreated EpochToReadable `json:"dateCreated,string"`
+	DateCreated EpochToReadable `json:"dateCreated,string" bson:"datecreated"`
 	Like        int             `json:"like"`
 }
 
@@ -37,17 +56,40 @@ type Report struct {
 	UserWithMostComments string             `json:"user_with_most_comments"`
 	HighestRatedGame     string             `json:"highest_rated_game"`
 	AverageLikesPerGame  []GameAverageLikes `json:"average_likes_per_game"`
+	// NewestComment is when the newest comment counted by the report was
+	// made. It tells clients when the report last changed, so isn't part of
+	// the report itself.
+	NewestComment time.Time `json:"-" bson:"-"`
 }
 
-// GameAverageLikes holds data for the average likes for a specific game.
+// GameAverageLikes holds statistics about the likes given to the comments on a
+// specific game.
 type GameAverageLikes struct {
-	Title        string `json:"title"`
-	AverageLikes int    `json:"average_likes"`
+	Title        string  `json:"title"`
+	Comments     int     `json:"comments"`
+	TotalLikes   int     `json:"total_likes"`
+	AverageLikes float64 `json:"average_likes"`
+	MedianLikes  float64 `json:"median_likes"`
+	MinLikes     int     `json:"min_likes"`
+	MaxLikes     int     `json:"max_likes"`
+	StdDevLikes  float64 `json:"std_dev_likes"`
+}
+
+// GameStats holds statistics about the comments on a single game.
+type GameStats struct {
+	GameAverageLikes
+	UniqueCommenters int              `json:"unique_commenters"`
+	FirstComment     *EpochToReadable `json:"first_comment,omitempty"`
+	LastComment      *EpochToReadable `json:"last_comment,omitempty"`
+	TopCommenters    []CommenterStats `json:"top_commenters"`
 }
 
-// Error is the Services error response format.
-type Error struct {
-	Msg string `json:"error"`
+// CommenterStats holds the number of comments a user made on a game and the
+// likes those comments received.
+type CommenterStats struct {
+	User     string `json:"user"`
+	Comments int    `json:"comments"`
+	Likes    int    `json:"likes"`
 }
 
 // The time format used to format the date
@@ -113,3 +155,25 @@ type User struct {
 	Name     string               `bson:"name"`
 	Comments []primitive.ObjectID `bson:"comments"`
 }
+
+// TimeSeries holds comment activity grouped into buckets of equal width, both
+// across every game and for each game individually.
+type TimeSeries struct {
+	Interval Interval         `json:"interval"`
+	Overall  []TimeBucket     `json:"overall"`
+	Games    []GameTimeSeries `json:"games"`
+}
+
+// GameTimeSeries holds the comment activity for a specific game.
+type GameTimeSeries struct {
+	Title   string       `json:"title"`
+	Buckets []TimeBucket `json:"buckets"`
+}
+
+// TimeBucket holds the number of comments and the likes they received for the
+// period beginning at Start.
+type TimeBucket struct {
+	Start    EpochToReadable `json:"start"`
+	Comments int             `json:"comments"`
+	Likes    int             `json:"likes"`
+}
@@ -2,35 +2,87 @@ package backend
 
 import (
 	"context"
-	"math"
+	"errors"
+	"fmt"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/DHBosworth/technichalexercise/logging"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-const (
-	gameDatabaseName        = "gamesService"
-	gameCollectionName      = "denormalisedGames"
-	commentsCollectionName  = "comments"
-	publisherCollectionName = "publishers"
-	userCollectionName      = "users"
-)
+// MongoConfig configures the database and collections a MongoDataSource
+// uses and how long it waits for the database.
+type MongoConfig struct {
+	URI               string `yaml:"uri"`
+	Database          string `yaml:"database"`
+	GamesCollection   string `yaml:"games_collection"`
+	ReportsCollection string `yaml:"reports_collection"`
+	// ConnectTimeout is how long NewMongoDataSource waits for the database
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	// DisconnectTimeout is how long Close waits for operations in progress
+	DisconnectTimeout time.Duration `yaml:"disconnect_timeout"`
+}
+
+// DefaultMongoConfig returns the configuration for a local database
+func DefaultMongoConfig() MongoConfig {
+	return MongoConfig{
+		URI:               "mongodb://localhost:27017",
+		Database:          "gamesService",
+		GamesCollection:   "denormalisedGames",
+		ReportsCollection: "reports",
+		ConnectTimeout:    10 * time.Second,
+		DisconnectTimeout: 10 * time.Second,
+	}
+}
+
+// Validate checks every field of the configuration is set
+func (config MongoConfig) Validate() error {
+	required := []struct{ name, value string }{
+		{"uri", config.URI},
+		{"database", config.Database},
+		{"games_collection", config.GamesCollection},
+		{"reports_collection", config.ReportsCollection},
+	}
+	for _, field := range required {
+		if field.value == "" {
+			return fmt.Errorf("Mongo %s must be set", field.name)
+		}
+	}
+
+	if config.ConnectTimeout <= 0 || config.DisconnectTimeout <= 0 {
+		return errors.New("Mongo timeouts must be greater than zero")
+	}
+
+	return nil
+}
+
+// errNoDocuments is mongo.ErrNoDocuments, which is shadowed by the receiver
+// name inside MongoDataSource's methods
+var errNoDocuments = mongo.ErrNoDocuments
 
 // MongoDataSource implements backend.ServiceDataSource so that it can be used
 // as the backend for the microservice
 type MongoDataSource struct {
 	client        *mongo.Client
 	gamesDatabase *mongo.Database
+	config        MongoConfig
 }
 
-// NewMongoDataSource creates a new mongo data source
-func NewMongoDataSource(addr string) (dataSource *MongoDataSource, err error) {
-	options := options.Client().ApplyURI(addr)
+// NewMongoDataSource creates a new mongo data source. Any client options,
+// such as a pool monitor, are applied after the URI.
+func NewMongoDataSource(config MongoConfig, opts ...*options.ClientOptions) (dataSource *MongoDataSource, err error) {
+	if err := config.Validate(); err != nil {
+		return dataSource, err
+	}
+
+	options := options.MergeClientOptions(append([]*options.ClientOptions{options.Client().ApplyURI(config.URI)}, opts...)...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ConnectTimeout)
+	defer cancel()
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
 	client, err := mongo.Connect(ctx, options)
 	if err != nil {
 		return dataSource, err
@@ -42,58 +94,235 @@ func NewMongoDataSource(addr string) (dataSource *MongoDataSource, err error) {
 
 	return &MongoDataSource{
 		client:        client,
-		gamesDatabase: client.Database(gameDatabaseName),
+		gamesDatabase: client.Database(config.Database),
+		config:        config,
 	}, err
 }
 
-// Game retrieves information for a game with the given id
-func (mongo *MongoDataSource) Game(id string) (game Game, err error) {
-	gameCollection := mongo.gamesDatabase.Collection(gameCollectionName)
-	filter := bson.M{"id": id}
+// Close disconnects from the database, waiting up to the configured
+// DisconnectTimeout for operations in progress to finish
+func (mongo *MongoDataSource) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongo.config.DisconnectTimeout)
+	defer cancel()
+
+	return mongo.client.Disconnect(ctx)
+}
+
+// Game retrieves information for a game with the given id. Games hidden by
+// WithMaxAge aren't found.
+func (mongo *MongoDataSource) Game(ctx context.Context, id string) (game Game, err error) {
+	gameCollection := mongo.gamesDatabase.Collection(mongo.config.GamesCollection)
+	filter := visibleFilter(ctx, bson.M{"id": id})
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
 	err = gameCollection.FindOne(ctx, filter).Decode(&game)
+	return game, gameError(err)
+}
+
+// gameError converts the error from finding a single game, so that a missing
+// game is ErrGameNotFound
+func gameError(err error) error {
+	if err == errNoDocuments {
+		return ErrGameNotFound
+	}
+
+	return err
+}
+
+// GameStats calculates statistics for the game with the given id, listing at
+// most top commenters. Only the title and comments of the game are fetched.
+func (mongo *MongoDataSource) GameStats(ctx context.Context, id string, top int) (stats GameStats, err error) {
+	gameCollection := mongo.gamesDatabase.Collection(mongo.config.GamesCollection)
+	filter := visibleFilter(ctx, bson.M{"id": id})
+	opts := options.FindOne().SetProjection(bson.D{
+		{"title", 1},
+		{"comments", 1},
+	})
+
+	var game Game
+	err = gameCollection.FindOne(ctx, filter, opts).Decode(&game)
 	if err != nil {
-		return game, err
+		return stats, gameError(err)
 	}
 
-	return game, err
+	return newGameStats(game, top), nil
 }
 
-// Report creates a report from the stored game data
-func (mongo *MongoDataSource) Report() (report Report, err error) {
-	report.UserWithMostComments, err = mongo.mostCommentedUser()
+// SimilarGames compares the game with the given id to the games sharing a
+// publisher, platform or commenter with it. Only the fields used for
+// comparison are fetched.
+func (mongo *MongoDataSource) SimilarGames(ctx context.Context, id string, limit int) ([]SimilarGame, error) {
+	gameCollection := mongo.gamesDatabase.Collection(mongo.config.GamesCollection)
+	projection := bson.D{
+		{"id", 1},
+		{"title", 1},
+		{"by", 1},
+		{"platform", 1},
+		{"age_rating", 1},
+		{"comments.user", 1},
+	}
+
+	var game Game
+	opts := options.FindOne().SetProjection(projection)
+	err := gameCollection.FindOne(ctx, visibleFilter(ctx, bson.M{"id": id}), opts).Decode(&game)
+	if err != nil {
+		return nil, gameError(err)
+	}
+
+	related := bson.A{
+		bson.M{"platform": bson.M{"$in": nonNil(game.Platform)}},
+		bson.M{"comments.user": bson.M{"$in": nonNil(commenters(game))}},
+	}
+	if game.By != "" {
+		related = append(related, bson.M{"by": game.By})
+	}
+
+	filter := visibleFilter(ctx, bson.M{
+		"id":  bson.M{"$ne": id},
+		"$or": related,
+	})
+
+	cur, err := gameCollection.Find(ctx, filter, options.Find().SetProjection(projection))
 	if err != nil {
-		log.Warnf("Unable to get user with most comments: %v", err)
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	acc := newSimilarAcc(game, limit)
+	for cur.Next(ctx) {
+		var candidate Game
+		if err := cur.Decode(&candidate); err != nil {
+			return nil, err
+		}
+		acc.processGame(candidate)
 	}
 
-	err = mongo.gamesReport(&report)
+	return acc.result(), cur.Err()
+}
+
+// nonNil replaces a nil slice with an empty one, as $in requires an array
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
+
+// Report creates a report from the stored game data. Progress is reported as
+// each game is analysed, see WithProgress. An aggregation failing fails the
+// whole report, so that incomplete reports aren't mistaken for complete ones.
+func (mongo *MongoDataSource) Report(ctx context.Context) (report Report, err error) {
+	var games []GameAverageLikes
+	report, err = mongo.StreamReport(ctx, func(stats GameAverageLikes) error {
+		games = append(games, stats)
+		return nil
+	})
 	if err != nil {
-		log.Warnf("Unable to analyse likes for report: %v", err)
+		return report, err
 	}
+	report.AverageLikesPerGame = games
 
 	return report, nil
 }
 
-func (mongo *MongoDataSource) mostCommentedUser() (name string, err error) {
-	gamesCollection := mongo.gamesDatabase.Collection(gameCollectionName)
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+// StreamReport calls row with the statistics for each game, most liked first,
+// as they are read from the database. Only one batch of games is held in
+// memory at a time. The leaders of the report are returned once every game
+// has been processed.
+func (mongo *MongoDataSource) StreamReport(ctx context.Context, row func(GameAverageLikes) error) (summary Report, err error) {
+	summary.UserWithMostComments, err = mongo.mostCommentedUser(ctx)
+	if err != nil {
+		return summary, fmt.Errorf("Unable to get user with most comments: %w", err)
+	}
+
+	summary.NewestComment, err = mongo.gamesReport(ctx, func(stats GameAverageLikes) error {
+		if summary.HighestRatedGame == "" {
+			summary.HighestRatedGame = stats.Title
+		}
+
+		return row(stats)
+	})
 
-	cur, err := gamesCollection.Aggregate(ctx, commentsPerUserPipeline())
+	return summary, err
+}
+
+// ReportSections computes the named report sections, running the aggregation
+// for each in turn
+func (mongo *MongoDataSource) ReportSections(ctx context.Context, names []string) (map[string]interface{}, error) {
+	sections, err := lookupReportSections(names)
 	if err != nil {
-		log.Warnf("Error: %v", err)
-		return name, err
+		return nil, err
 	}
 
-	var bestUser userResult
-	if cur.Next(ctx) {
-		err := cur.Decode(&bestUser)
+	for _, section := range sections {
+		if section.Pipeline == nil || section.Decode == nil {
+			return nil, unsupportedSectionError(section.Name)
+		}
+	}
+
+	groups, err := groupSectionsByPipeline(sections)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]interface{}, len(sections))
+	for _, group := range groups {
+		if err := mongo.reportSectionGroup(ctx, group, results); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// reportSectionGroup runs the pipeline shared by a group of sections once and
+// decodes each section's result from it into results
+func (mongo *MongoDataSource) reportSectionGroup(ctx context.Context, group sectionGroup, results map[string]interface{}) error {
+	gamesCollection := mongo.gamesDatabase.Collection(mongo.config.GamesCollection)
+
+	opts := options.Aggregate().SetAllowDiskUse(true)
+	cur, err := aggregate(ctx, gamesCollection, "section_"+group.name(), group.pipeline, opts)
+	if err != nil {
+		return fmt.Errorf("Unable to compute report section %s: %v", group.name(), err)
+	}
+	defer cur.Close(ctx)
+
+	if len(group.sections) == 1 {
+		section := group.sections[0]
+		results[section.Name], err = section.Decode(ctx, cur)
+		if err != nil {
+			return fmt.Errorf("Unable to compute report section %s: %v", section.Name, err)
+		}
+		return nil
+	}
+
+	// Each section reads the documents from the start, so they are kept
+	docs, err := readDocuments(ctx, cur)
+	if err != nil {
+		return fmt.Errorf("Unable to compute report section %s: %v", group.name(), err)
+	}
+
+	for _, section := range group.sections {
+		results[section.Name], err = section.Decode(ctx, &documentCursor{docs: docs})
 		if err != nil {
-			log.Warnf("Error decoding user: %v", err)
+			return fmt.Errorf("Unable to compute report section %s: %v", section.Name, err)
 		}
 	}
 
-	return bestUser.Name, err
+	return nil
+}
+
+func (mongo *MongoDataSource) mostCommentedUser(ctx context.Context) (name string, err error) {
+	gamesCollection := mongo.gamesDatabase.Collection(mongo.config.GamesCollection)
+
+	cur, err := aggregate(ctx, gamesCollection, "comments_per_user", commentsPerUserPipeline())
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Error: %v", err)
+		return name, err
+	}
+	defer cur.Close(ctx)
+
+	return decodeMostCommentedUser(ctx, cur)
 }
 
 type userResult struct {
@@ -101,109 +330,318 @@ type userResult struct {
 	Comments int    `bson:"number_of_comments"`
 }
 
-func (mongo *MongoDataSource) gamesReport(report *Report) error {
-	gamesCollection := mongo.gamesDatabase.Collection(gameCollectionName)
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+// decodeMostCommentedUser reads the user with the most comments from the
+// cursor of a commentsPerUserPipeline
+func decodeMostCommentedUser(ctx context.Context, cur SectionCursor) (string, error) {
+	var bestUser userResult
+	if cur.Next(ctx) {
+		if err := cur.Decode(&bestUser); err != nil {
+			return "", err
+		}
+	}
+
+	return bestUser.Name, cur.Err()
+}
 
-	cur, err := gamesCollection.Aggregate(ctx, gameLikePipeline())
+// streamBatchSize is the number of games read from the database at a time
+// when creating a report
+const streamBatchSize = 500
+
+// gamesReport calls row with the statistics for each game, returning when the
+// newest comment on any of them was made
+func (mongo *MongoDataSource) gamesReport(ctx context.Context, row func(GameAverageLikes) error) (newest time.Time, err error) {
+	gamesCollection := mongo.gamesDatabase.Collection(mongo.config.GamesCollection)
+
+	// Counting is only worth the cost of a query when games are filtered
+	var total int64
+	if _, filtered := MaxAge(ctx); filtered {
+		total, err = gamesCollection.CountDocuments(ctx, visibleFilter(ctx, bson.M{}))
+	} else {
+		total, err = gamesCollection.EstimatedDocumentCount(ctx)
+	}
 	if err != nil {
-		return err
+		return newest, err
+	}
+
+	opts := options.Aggregate().
+		SetBatchSize(streamBatchSize).
+		SetAllowDiskUse(true)
+
+	cur, err := aggregate(ctx, gamesCollection, "game_likes", gameLikePipeline(), opts)
+	if err != nil {
+		return newest, err
 	}
 	defer cur.Close(ctx)
 
-	first := true
-	for cur.Next(ctx) {
-		var res gameLikeResult
-		err = cur.Decode(&res)
-		if err != nil {
-			return err
+	done := 0
+	err = decodeGameLikes(ctx, cur, func(res gameLikeResult) error {
+		if created := time.Unix(res.NewestComment, 0); res.NewestComment != 0 && created.After(newest) {
+			newest = created
 		}
 
-		if first {
-			report.HighestRatedGame = res.Title
+		if err := row(res.stats()); err != nil {
+			return err
 		}
 
-		report.AverageLikesPerGame = append(report.AverageLikesPerGame, GameAverageLikes{
-			Title:        res.Title,
-			AverageLikes: int(math.Ceil(res.AvgLikes)),
-		})
-	}
+		done++
+		reportProgress(ctx, done, int(total))
+		return nil
+	})
 
-	return nil
+	return newest, err
 }
 
 type gameLikeResult struct {
-	Title    string  `bson:"_id"`
-	Likes    int     `bson:"likes"`
-	AvgLikes float64 `bson:"avg_likes"`
+	Title      string `bson:"title"`
+	Likes      []int  `bson:"likes"`
+	TotalLikes int    `bson:"total_likes"`
+	// NewestComment is the epoch time of the newest comment, or 0
+	NewestComment int64 `bson:"newest_comment"`
 }
 
-func gameLikePipeline() []bson.D {
-	getComments := bson.D{
-		{"$unwind", bson.D{
-			{"path", "$comments"},
-			{"includeArrayIndex", "string"},
-			{"preserveNullAndEmptyArrays", true},
-		}},
+// stats calculates the game's statistics from its likes
+func (res gameLikeResult) stats() GameAverageLikes {
+	return newGameAverageLikes(res.Title, res.Likes)
+}
+
+// errStopDecoding stops decodeGameLikes without an error
+var errStopDecoding = errors.New("Stop decoding")
+
+// decodeGameLikes calls row with each game read from the cursor of a
+// gameLikePipeline, most liked first, until row returns an error.
+// errStopDecoding stops early without returning an error.
+func decodeGameLikes(ctx context.Context, cur SectionCursor, row func(gameLikeResult) error) error {
+	for cur.Next(ctx) {
+		var res gameLikeResult
+		if err := cur.Decode(&res); err != nil {
+			return err
+		}
+
+		if err := row(res); err == errStopDecoding {
+			return nil
+		} else if err != nil {
+			return err
+		}
 	}
 
-	projectComments := bson.D{
+	return cur.Err()
+}
+
+// gameLikePipeline collects the likes of every comment on each game. The
+// statistics are calculated from the likes once decoded so that games without
+// any comments are handled the same way as in memory.
+func gameLikePipeline() []bson.D {
+	projectLikes := bson.D{
 		{
 			"$project", bson.D{
-				{"comment", "$comments"},
 				{"title", "$title"},
-			},
-		},
-	}
-
-	groupByTitle := bson.D{
-		{
-			"$group", bson.D{
 				{
-					"_id", "$title",
+					"likes", bson.D{
+						{"$ifNull", bson.A{"$comments.like", bson.A{}}},
+					},
 				},
 				{
-					"number_of_comments", bson.D{{"$sum", 1}},
+					"total_likes", bson.D{{"$sum", "$comments.like"}},
 				},
 				{
-					"total_likes", bson.D{{"$sum", "$comment.like"}},
+					"newest_comment", bson.D{
+						{"$ifNull", bson.A{bson.D{{"$max", "$comments." + commentDateField}}, 0}},
+					},
 				},
 			},
 		},
 	}
 
-	averageProjection := bson.D{
+	sort := bson.D{
+		{"$sort", bson.D{{"total_likes", -1}}},
+	}
+
+	return []bson.D{
+		projectLikes,
+		sort,
+	}
+}
+
+// TimeSeries groups the comments on every game into buckets of the given
+// interval
+func (mongo *MongoDataSource) TimeSeries(ctx context.Context, interval Interval) (series TimeSeries, err error) {
+	gamesCollection := mongo.gamesDatabase.Collection(mongo.config.GamesCollection)
+
+	cur, err := aggregate(ctx, gamesCollection, "time_series", timeSeriesPipeline(interval))
+	if err != nil {
+		return series, err
+	}
+	defer cur.Close(ctx)
+
+	acc := newTimeSeriesAcc(interval)
+	for cur.Next(ctx) {
+		var res timeSeriesResult
+		if err := cur.Decode(&res); err != nil {
+			return series, err
+		}
+
+		acc.add(res.ID.Title, res.ID.Start, res.Comments, res.Likes)
+	}
+
+	if err := cur.Err(); err != nil {
+		return series, err
+	}
+
+	return acc.timeSeries(), nil
+}
+
+// Trending ranks games by the decayed score of the comments made on them
+// within the window. Scoring and ranking is done by the database.
+func (mongo *MongoDataSource) Trending(ctx context.Context, opts TrendingOptions) (trending Trending, err error) {
+	gamesCollection := mongo.gamesDatabase.Collection(mongo.config.GamesCollection)
+
+	trending.From, trending.To = opts.window()
+	pipeline := trendingPipeline(trending.From, trending.To, opts.HalfLife)
+	if opts.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{"$limit", opts.Limit}})
+	}
+
+	cur, err := aggregate(ctx, gamesCollection, "trending", pipeline)
+	if err != nil {
+		return trending, err
+	}
+	defer cur.Close(ctx)
+
+	trending.Games = make([]TrendingGame, 0)
+	for cur.Next(ctx) {
+		var res trendingResult
+		if err := cur.Decode(&res); err != nil {
+			return trending, err
+		}
+
+		trending.Games = append(trending.Games, TrendingGame(res))
+	}
+
+	return trending, cur.Err()
+}
+
+type timeSeriesResult struct {
+	ID struct {
+		Title string    `bson:"title"`
+		Start time.Time `bson:"start"`
+	} `bson:"_id"`
+	Comments int `bson:"comments"`
+	Likes    int `bson:"likes"`
+}
+
+// timeSeriesPipeline counts the comments and likes for each game in buckets of
+// the given interval. Comment dates are stored as unix timestamps so they are
+// converted to dates before being truncated.
+func timeSeriesPipeline(interval Interval) []bson.D {
+	getComments := bson.D{
+		{"$unwind", "$comments"},
+	}
+
+	projectComments := bson.D{
 		{
 			"$project", bson.D{
+				{"title", "$title"},
+				{"like", "$comments.like"},
 				{
-					"avg_likes", bson.D{
-						{"$divide", bson.A{"$total_likes", "$number_of_comments"}},
+					"date", bson.D{
+						{"$toDate", bson.D{
+							{"$multiply", bson.A{"$comments." + commentDateField, 1000}},
+						}},
 					},
 				},
-				{"likes", "$total_likes"},
 			},
 		},
 	}
 
-	sort := bson.D{
-		{"$sort", bson.D{{"likes", -1}}},
+	groupByBucket := bson.D{
+		{
+			"$group", bson.D{
+				{
+					"_id", bson.D{
+						{"title", "$title"},
+						{"start", bson.D{
+							{"$dateTrunc", bson.D{
+								{"date", "$date"},
+								{"unit", string(interval)},
+								{"startOfWeek", "monday"},
+							}},
+						}},
+					},
+				},
+				{"comments", bson.D{{"$sum", 1}}},
+				{"likes", bson.D{{"$sum", "$like"}}},
+			},
+		},
 	}
 
 	return []bson.D{
 		getComments,
 		projectComments,
-		groupByTitle,
-		averageProjection,
-		sort,
+		groupByBucket,
 	}
 }
 
+// SaveReport persists a report snapshot in the reports collection
+func (mongo *MongoDataSource) SaveReport(ctx context.Context, snapshot ReportSnapshot) error {
+	reportsCollection := mongo.gamesDatabase.Collection(mongo.config.ReportsCollection)
+
+	_, err := reportsCollection.InsertOne(ctx, snapshot)
+	return err
+}
+
+// ReportHistory retrieves the snapshots taken between from and to inclusive
+func (mongo *MongoDataSource) ReportHistory(ctx context.Context, from, to time.Time) ([]ReportSnapshot, error) {
+	reportsCollection := mongo.gamesDatabase.Collection(mongo.config.ReportsCollection)
+
+	filter := bson.D{
+		{"taken_at", bson.D{
+			{"$gte", from},
+			{"$lte", to},
+		}},
+	}
+	opts := options.Find().SetSort(bson.D{{"taken_at", 1}})
+
+	cur, err := reportsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]ReportSnapshot, 0)
+	if err := cur.All(ctx, &history); err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// ReportAt retrieves the latest snapshot taken at or before t
+func (mongo *MongoDataSource) ReportAt(ctx context.Context, t time.Time) (snapshot ReportSnapshot, err error) {
+	reportsCollection := mongo.gamesDatabase.Collection(mongo.config.ReportsCollection)
+
+	filter := bson.D{
+		{"taken_at", bson.D{{"$lte", t}}},
+	}
+	opts := options.FindOne().SetSort(bson.D{{"taken_at", -1}})
+
+	err = reportsCollection.FindOne(ctx, filter, opts).Decode(&snapshot)
+	if err == errNoDocuments {
+		return snapshot, ErrSnapshotNotFound
+	}
+
+	return snapshot, err
+}
+
+// commentsPerUserPipeline counts the comments of each user, most first with
+// ties in order of name, as reportAccumulator does. Games without comments and
+// comments without a user aren't counted, so they can't form a null user.
 func commentsPerUserPipeline() []bson.D {
 	getComments := bson.D{
-		{"$unwind", bson.D{
-			{"path", "$comments"},
-			{"includeArrayIndex", "string"},
-			{"preserveNullAndEmptyArrays", true},
+		{"$unwind", "$comments"},
+	}
+
+	matchUsers := bson.D{
+		{"$match", bson.D{
+			{"comments.user", bson.D{{"$nin", bson.A{nil, ""}}}},
 		}},
 	}
 
@@ -230,14 +668,54 @@ func commentsPerUserPipeline() []bson.D {
 		{
 			"$sort", bson.D{
 				{"number_of_comments", -1},
+				{"_id", 1},
 			},
 		},
 	}
 
 	return []bson.D{
 		getComments,
+		matchUsers,
 		projectComments,
 		groupByName,
 		sort,
 	}
 }
+
+// visibleFilter adds a condition to filter so it only matches games suitable
+// for the age set by WithMaxAge. The ratings are matched using the same
+// grammar ParseAgeRating accepts.
+func visibleFilter(ctx context.Context, filter bson.M) bson.M {
+	age, ok := MaxAge(ctx)
+	if !ok {
+		return filter
+	}
+
+	filter["age_rating"] = primitive.Regex{
+		Pattern: ageRatingPattern(age),
+		Options: "i",
+	}
+
+	return filter
+}
+
+// visiblePipeline prepends a stage to pipeline dropping the games hidden by
+// WithMaxAge
+func visiblePipeline(ctx context.Context, pipeline []bson.D) []bson.D {
+	if _, ok := MaxAge(ctx); !ok {
+		return pipeline
+	}
+
+	return append([]bson.D{{{"$match", visibleFilter(ctx, bson.M{})}}}, pipeline...)
+}
+
+// aggregate runs the named pipeline on collection with the games hidden by
+// WithMaxAge removed, reporting it to any PipelineObserver on ctx. The time
+// observed is until the database returns the first batch of results.
+func aggregate(ctx context.Context, collection *mongo.Collection, name string, pipeline []bson.D, opts ...*options.AggregateOptions) (*mongo.Cursor, error) {
+	start := time.Now()
+	cur, err := collection.Aggregate(ctx, visiblePipeline(ctx, pipeline), opts...)
+	observePipeline(ctx, name, start, err)
+
+	return cur, err
+}
@@ -0,0 +1,54 @@
+package backend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestComment_dateField(t *testing.T) {
+	doc, err := bson.Marshal(Comment{User: "a"})
+	if err != nil {
+		t.Fatalf("Error encoding comment: %v", err)
+	}
+
+	_, err = bson.Raw(doc).LookupErr(commentDateField)
+	assert.NoError(t, err, "Comments should store their date in commentDateField")
+}
+
+func Test_pipelinesUseCommentDateField(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline []bson.D
+	}{
+		{"Game likes", gameLikePipeline()},
+		{"Time series", timeSeriesPipeline(IntervalDay)},
+		{"Trending", trendingPipeline(time.Unix(0, 0), time.Unix(3600, 0), time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			json, err := bson.MarshalExtJSON(bson.D{{"pipeline", tt.pipeline}}, false, false)
+			if err != nil {
+				t.Fatalf("Error encoding pipeline: %v", err)
+			}
+
+			assert.Contains(t, string(json), "comments."+commentDateField)
+			assert.NotContains(t, string(json), "comments.dateCreated", "The JSON name of the date isn't stored")
+		})
+	}
+}
+
+func Test_commentsPerUserPipeline(t *testing.T) {
+	pipeline := commentsPerUserPipeline()
+
+	// Comments without a user would be grouped as a null user, which could
+	// have the most comments
+	assert.Equal(t, bson.D{{"$unwind", "$comments"}}, pipeline[0], "Games without comments shouldn't be kept")
+	assert.Equal(t, bson.D{{"$match", bson.D{{"comments.user", bson.D{{"$nin", bson.A{nil, ""}}}}}}}, pipeline[1],
+		"Comments without a user shouldn't be counted")
+
+	assert.Equal(t, bson.D{{"$sort", bson.D{{"number_of_comments", -1}, {"_id", 1}}}}, pipeline[len(pipeline)-1],
+		"Ties should be broken by name, as they are by reportAccumulator")
+}
@@ -1,33 +1,39 @@
 package backend
 
 import (
-	"math"
+	"sort"
+	"time"
 )
 
+// reportAccumulator works out the leaders of a report as games are processed.
+// The statistics for each game are returned rather than kept so that reports
+// can be streamed without holding every game in memory.
 type reportAccumulator struct {
 	users     map[string]int
 	mostLiked struct {
 		title string
 		likes int
 	}
-	averageLikes []GameAverageLikes
+	newestComment time.Time
 }
 
 func newReportAcc() *reportAccumulator {
 	return &reportAccumulator{
-		users:        make(map[string]int),
-		averageLikes: make([]GameAverageLikes, 0),
+		users: make(map[string]int),
 	}
 }
 
-func (acc *reportAccumulator) report() (report Report) {
+// summary returns a report with the leaders found so far. Its
+// AverageLikesPerGame is left unset.
+func (acc *reportAccumulator) summary() (report Report) {
 	var (
 		maxComments = 0
 		maxName     = ""
 	)
 
 	for name, comments := range acc.users {
-		if comments > maxComments {
+		if comments > maxComments || (comments == maxComments && name < maxName) {
+			maxComments = comments
 			maxName = name
 		}
 	}
@@ -35,34 +41,48 @@ func (acc *reportAccumulator) report() (report Report) {
 	return Report{
 		UserWithMostComments: maxName,
 		HighestRatedGame:     acc.mostLiked.title,
-		AverageLikesPerGame:  acc.averageLikes,
+		NewestComment:        acc.newestComment,
 	}
 }
 
-func (acc *reportAccumulator) processGame(game Game) {
-	avg, total := processLikes(game)
-	if total > acc.mostLiked.likes {
-		acc.mostLiked.likes = total
+func (acc *reportAccumulator) processGame(game Game) GameAverageLikes {
+	stats := processLikes(game)
+	if stats.TotalLikes > acc.mostLiked.likes {
+		acc.mostLiked.likes = stats.TotalLikes
 		acc.mostLiked.title = game.Title
 	}
-	acc.averageLikes = append(acc.averageLikes, GameAverageLikes{
-		Title:        game.Title,
-		AverageLikes: avg,
-	})
+
+	if newest := game.NewestComment(); newest.After(acc.newestComment) {
+		acc.newestComment = newest
+	}
 
 	for _, comment := range game.Comments {
+		// Comments without a user aren't counted, as in commentsPerUserPipeline
+		if comment.User == "" {
+			continue
+		}
+
 		// Will default to zero
 		numberOfComments := acc.users[comment.User]
 		acc.users[comment.User] = numberOfComments + 1
 	}
+
+	return stats
 }
 
-func processLikes(game Game) (avg, total int) {
-	likeSum := 0
-	for _, comment := range game.Comments {
-		likeSum += comment.Like
+func processLikes(game Game) GameAverageLikes {
+	likes := make([]int, len(game.Comments))
+	for i, comment := range game.Comments {
+		likes[i] = comment.Like
 	}
 
-	avgLikes := int(math.Ceil(float64(likeSum) / float64(len(game.Comments))))
-	return avgLikes, likeSum
+	return newGameAverageLikes(game.Title, likes)
+}
+
+// sortByTotalLikes orders games from most to least liked, the order used by
+// reports.
+func sortByTotalLikes(games []GameAverageLikes) {
+	sort.SliceStable(games, func(i, j int) bool {
+		return games[i].TotalLikes > games[j].TotalLikes
+	})
 }
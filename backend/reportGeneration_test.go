@@ -0,0 +1,73 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func commentsBy(users ...string) []Comment {
+	comments := make([]Comment, len(users))
+	for i, user := range users {
+		comments[i] = Comment{User: user}
+	}
+
+	return comments
+}
+
+func Test_reportAccumulator_mostCommentedUser(t *testing.T) {
+	tests := []struct {
+		name  string
+		games []Game
+		want  string
+	}{
+		{
+			name: "No comments",
+			want: "",
+		},
+		{
+			name: "Most comments",
+			games: []Game{
+				{Title: "A", Comments: commentsBy("b", "a", "a")},
+				{Title: "B", Comments: commentsBy("c", "a", "b")},
+			},
+			want: "a",
+		},
+		{
+			name: "Fewer comments seen later",
+			games: []Game{
+				{Title: "A", Comments: commentsBy("a", "a", "a", "b", "b", "c")},
+			},
+			want: "a",
+		},
+		{
+			name: "Tie broken by name",
+			games: []Game{
+				{Title: "A", Comments: commentsBy("z", "y", "x", "y", "z", "x")},
+			},
+			want: "x",
+		},
+		{
+			name: "Comments without a user",
+			games: []Game{
+				{Title: "A", Comments: commentsBy("", "", "", "b")},
+				{Title: "B"},
+			},
+			want: "b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration order is random, so repeat to catch order
+			// dependent results
+			for i := 0; i < 20; i++ {
+				acc := newReportAcc()
+				for _, game := range tt.games {
+					acc.processGame(game)
+				}
+
+				assert.Equal(t, tt.want, acc.summary().UserWithMostComments)
+			}
+		})
+	}
+}
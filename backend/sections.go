@@ -0,0 +1,275 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ReportSection computes one named part of a report. MongoDataSource computes
+// a section by running Pipeline against the games collection and reading the
+// result with Decode. MemoryDataSource feeds every game to the
+// SectionAccumulator created by Accumulator. A section may leave out the
+// functions for a data source it doesn't support.
+type ReportSection struct {
+	// Name identifies the section in requests and is the key of its result.
+	Name string
+	// Pipeline builds the aggregation run by MongoDataSource.
+	Pipeline func() []bson.D
+	// Decode reads the section's result from the pipeline's cursor. Sections
+	// with the same pipeline share one aggregation when requested together.
+	Decode func(ctx context.Context, cur SectionCursor) (interface{}, error)
+	// Accumulator creates the accumulator used by MemoryDataSource.
+	Accumulator func() SectionAccumulator
+}
+
+// SectionCursor reads the documents produced by a section's pipeline. It is
+// implemented by *mongo.Cursor.
+type SectionCursor interface {
+	Next(ctx context.Context) bool
+	Decode(val interface{}) error
+	Err() error
+}
+
+// documentCursor replays documents already read from a cursor, so that
+// several sections can decode the result of one aggregation
+type documentCursor struct {
+	docs []bson.Raw
+	next int
+	err  error
+}
+
+func (cur *documentCursor) Next(ctx context.Context) bool {
+	if cur.err = ctx.Err(); cur.err != nil || cur.next >= len(cur.docs) {
+		return false
+	}
+
+	cur.next++
+	return true
+}
+
+func (cur *documentCursor) Decode(val interface{}) error {
+	return bson.Unmarshal(cur.docs[cur.next-1], val)
+}
+
+func (cur *documentCursor) Err() error {
+	return cur.err
+}
+
+// readDocuments reads every remaining document from cur
+func readDocuments(ctx context.Context, cur *mongo.Cursor) ([]bson.Raw, error) {
+	docs := make([]bson.Raw, 0)
+	for cur.Next(ctx) {
+		// Current is only valid until the next call to Next
+		docs = append(docs, append(bson.Raw(nil), cur.Current...))
+	}
+
+	return docs, cur.Err()
+}
+
+// sectionGroup is the sections which have the same pipeline
+type sectionGroup struct {
+	pipeline []bson.D
+	sections []ReportSection
+}
+
+// name identifies the group in metrics and errors
+func (group sectionGroup) name() string {
+	names := make([]string, len(group.sections))
+	for i, section := range group.sections {
+		names[i] = section.Name
+	}
+
+	return strings.Join(names, "+")
+}
+
+// groupSectionsByPipeline groups sections by their pipeline, in the order each
+// pipeline is first needed, so that each is run once. Pipelines are compared
+// by what they contain, as the functions building them can't be compared.
+func groupSectionsByPipeline(sections []ReportSection) ([]sectionGroup, error) {
+	groups := make([]sectionGroup, 0, len(sections))
+	index := make(map[string]int, len(sections))
+	for _, section := range sections {
+		pipeline := section.Pipeline()
+		key, err := bson.MarshalExtJSON(bson.D{{"pipeline", pipeline}}, true, false)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid pipeline for report section %s: %v", section.Name, err)
+		}
+
+		if i, ok := index[string(key)]; ok {
+			groups[i].sections = append(groups[i].sections, section)
+			continue
+		}
+
+		index[string(key)] = len(groups)
+		groups = append(groups, sectionGroup{pipeline: pipeline, sections: []ReportSection{section}})
+	}
+
+	return groups, nil
+}
+
+// SectionAccumulator computes a report section in memory one game at a time.
+type SectionAccumulator interface {
+	Add(game Game)
+	Result() interface{}
+}
+
+// AccumulatorFuncs adapts a pair of functions to a SectionAccumulator.
+type AccumulatorFuncs struct {
+	AddFunc    func(game Game)
+	ResultFunc func() interface{}
+}
+
+// Add calls AddFunc
+func (acc AccumulatorFuncs) Add(game Game) {
+	acc.AddFunc(game)
+}
+
+// Result calls ResultFunc
+func (acc AccumulatorFuncs) Result() interface{} {
+	return acc.ResultFunc()
+}
+
+var (
+	sectionsMu sync.RWMutex
+	sections   = make(map[string]ReportSection)
+)
+
+// RegisterReportSection makes a report section available to every data
+// source. It is intended to be called from init functions and panics if the
+// section has no name or the name is already registered.
+func RegisterReportSection(section ReportSection) {
+	sectionsMu.Lock()
+	defer sectionsMu.Unlock()
+
+	if section.Name == "" {
+		panic("backend: RegisterReportSection called without a name")
+	}
+	if _, exists := sections[section.Name]; exists {
+		panic("backend: RegisterReportSection called twice for " + section.Name)
+	}
+
+	sections[section.Name] = section
+}
+
+// ReportSectionNames lists the registered report sections in name order.
+func ReportSectionNames() []string {
+	sectionsMu.RLock()
+	defer sectionsMu.RUnlock()
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// lookupReportSections finds the sections with the given names, returning an
+// error naming the first which isn't registered.
+func lookupReportSections(names []string) ([]ReportSection, error) {
+	sectionsMu.RLock()
+	defer sectionsMu.RUnlock()
+
+	found := make([]ReportSection, 0, len(names))
+	for _, name := range names {
+		section, ok := sections[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown report section %q", name)
+		}
+		found = append(found, section)
+	}
+
+	return found, nil
+}
+
+// unsupportedSectionError is returned when a data source is asked for a
+// section which can't be computed by it.
+func unsupportedSectionError(name string) error {
+	return fmt.Errorf("Report section %q is not supported by this data source", name)
+}
+
+// The names of the built in report sections, which match the fields of Report.
+// They are computed with the same helpers as Report, so that a section always
+// matches the field of a full report.
+const (
+	SectionUserWithMostComments = "user_with_most_comments"
+	SectionHighestRatedGame     = "highest_rated_game"
+	SectionAverageLikesPerGame  = "average_likes_per_game"
+)
+
+func init() {
+	RegisterReportSection(ReportSection{
+		Name:     SectionUserWithMostComments,
+		Pipeline: commentsPerUserPipeline,
+		Decode: func(ctx context.Context, cur SectionCursor) (interface{}, error) {
+			return decodeMostCommentedUser(ctx, cur)
+		},
+		Accumulator: func() SectionAccumulator {
+			acc := newReportAcc()
+			return AccumulatorFuncs{
+				AddFunc: func(game Game) { acc.processGame(game) },
+				ResultFunc: func() interface{} {
+					return acc.summary().UserWithMostComments
+				},
+			}
+		},
+	})
+
+	RegisterReportSection(ReportSection{
+		Name:     SectionHighestRatedGame,
+		Pipeline: gameLikePipeline,
+		Decode: func(ctx context.Context, cur SectionCursor) (interface{}, error) {
+			// The most liked game is first, as for Report
+			var title string
+			err := decodeGameLikes(ctx, cur, func(res gameLikeResult) error {
+				title = res.Title
+				return errStopDecoding
+			})
+
+			return title, err
+		},
+		Accumulator: func() SectionAccumulator {
+			acc := newReportAcc()
+			return AccumulatorFuncs{
+				AddFunc: func(game Game) { acc.processGame(game) },
+				ResultFunc: func() interface{} {
+					return acc.summary().HighestRatedGame
+				},
+			}
+		},
+	})
+
+	RegisterReportSection(ReportSection{
+		Name:     SectionAverageLikesPerGame,
+		Pipeline: gameLikePipeline,
+		Decode: func(ctx context.Context, cur SectionCursor) (interface{}, error) {
+			games := make([]GameAverageLikes, 0)
+			err := decodeGameLikes(ctx, cur, func(res gameLikeResult) error {
+				games = append(games, res.stats())
+				return nil
+			})
+
+			return games, err
+		},
+		Accumulator: func() SectionAccumulator {
+			acc := newReportAcc()
+			games := make([]GameAverageLikes, 0)
+			return AccumulatorFuncs{
+				AddFunc: func(game Game) {
+					games = append(games, acc.processGame(game))
+				},
+				ResultFunc: func() interface{} {
+					sortByTotalLikes(games)
+					return games
+				},
+			}
+		},
+	})
+}
@@ -0,0 +1,93 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRegisterReportSection(t *testing.T) {
+	RegisterReportSection(ReportSection{
+		Name: "test_game_count",
+		Accumulator: func() SectionAccumulator {
+			count := 0
+			return AccumulatorFuncs{
+				AddFunc:    func(game Game) { count++ },
+				ResultFunc: func() interface{} { return count },
+			}
+		},
+	})
+
+	assert.Contains(t, ReportSectionNames(), "test_game_count")
+	assert.Panics(t, func() {
+		RegisterReportSection(ReportSection{Name: "test_game_count"})
+	}, "Sections should only be registered once")
+
+	mem := NewMemoryDataSource(memoryGames)
+	sections, err := mem.ReportSections(context.Background(), []string{"test_game_count", SectionHighestRatedGame})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"test_game_count":       3,
+		SectionHighestRatedGame: "Loud",
+	}, sections)
+
+	_, err = mem.ReportSections(context.Background(), []string{"missing"})
+	assert.Error(t, err, "Unknown sections should be rejected")
+}
+
+func Test_groupSectionsByPipeline(t *testing.T) {
+	sections, err := lookupReportSections([]string{
+		SectionHighestRatedGame,
+		SectionUserWithMostComments,
+		SectionAverageLikesPerGame,
+	})
+	assert.NoError(t, err)
+
+	groups, err := groupSectionsByPipeline(sections)
+	assert.NoError(t, err)
+
+	names := make([]string, len(groups))
+	for i, group := range groups {
+		names[i] = group.name()
+	}
+	assert.Equal(t, []string{
+		SectionHighestRatedGame + "+" + SectionAverageLikesPerGame,
+		SectionUserWithMostComments,
+	}, names, "Sections with the same pipeline should share it")
+}
+
+func Test_documentCursor(t *testing.T) {
+	docs := make([]bson.Raw, 0)
+	for _, res := range []gameLikeResult{
+		{Title: "Loud", Likes: []int{4, 2}, TotalLikes: 6},
+		{Title: "Quiet", Likes: []int{1}, TotalLikes: 1},
+	} {
+		doc, err := bson.Marshal(res)
+		if err != nil {
+			t.Fatalf("Error encoding result: %v", err)
+		}
+		docs = append(docs, doc)
+	}
+
+	sections, err := lookupReportSections([]string{SectionHighestRatedGame, SectionAverageLikesPerGame})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	highest, err := sections[0].Decode(ctx, &documentCursor{docs: docs})
+	assert.NoError(t, err)
+	assert.Equal(t, "Loud", highest)
+
+	games, err := sections[1].Decode(ctx, &documentCursor{docs: docs})
+	assert.NoError(t, err)
+	assert.Equal(t, []GameAverageLikes{
+		newGameAverageLikes("Loud", []int{4, 2}),
+		newGameAverageLikes("Quiet", []int{1}),
+	}, games, "Each section should read every document")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = sections[1].Decode(cancelled, &documentCursor{docs: docs})
+	assert.Equal(t, context.Canceled, err)
+}
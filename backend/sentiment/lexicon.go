@@ -0,0 +1,286 @@
+package sentiment
+
+// lexicon holds the score of each word with a sentiment. It focuses on the
+// vocabulary found in game reviews and comments.
+var lexicon = map[string]int{
+	// Positive
+	"amazing":       4,
+	"awesome":       4,
+	"beautiful":     3,
+	"best":          3,
+	"better":        2,
+	"brilliant":     4,
+	"captivating":   3,
+	"charming":      3,
+	"clever":        2,
+	"cool":          1,
+	"delight":       3,
+	"delightful":    3,
+	"enjoy":         2,
+	"enjoyable":     2,
+	"enjoyed":       2,
+	"epic":          3,
+	"excellent":     3,
+	"excited":       3,
+	"exciting":      3,
+	"fantastic":     4,
+	"fav":           2,
+	"favorite":      2,
+	"favourite":     2,
+	"fine":          1,
+	"flawless":      4,
+	"fun":           3,
+	"funny":         2,
+	"gem":           3,
+	"glad":          2,
+	"good":          3,
+	"gorgeous":      3,
+	"great":         3,
+	"happy":         3,
+	"immersive":     3,
+	"impressed":     3,
+	"impressive":    3,
+	"incredible":    4,
+	"innovative":    2,
+	"like":          2,
+	"liked":         2,
+	"love":          3,
+	"loved":         3,
+	"lovely":        3,
+	"loves":         3,
+	"masterpiece":   5,
+	"nice":          3,
+	"outstanding":   5,
+	"perfect":       3,
+	"pleasant":      3,
+	"polished":      2,
+	"recommend":     2,
+	"recommended":   2,
+	"relaxing":      2,
+	"rewarding":     2,
+	"satisfying":    2,
+	"smooth":        2,
+	"solid":         2,
+	"stunning":      4,
+	"superb":        5,
+	"thanks":        2,
+	"thrilling":     3,
+	"top":           2,
+	"wonderful":     4,
+	"wow":           4,
+	"addictive":     2,
+	"beloved":       3,
+	"creative":      2,
+	"engaging":      2,
+	"entertaining":  2,
+	"fair":          2,
+	"friendly":      2,
+	"generous":      2,
+	"hilarious":     2,
+	"improved":      2,
+	"interesting":   2,
+	"joy":           3,
+	"masterful":     4,
+	"memorable":     2,
+	"playable":      1,
+	"pretty":        1,
+	"strong":        2,
+	"success":       2,
+	"unique":        2,
+	"well":          1,
+	"win":           4,
+	"winner":        4,
+	"worth":         2,
+	"worthwhile":    2,
+	"yay":           2,
+	"appreciate":    2,
+	"appreciated":   2,
+	"classic":       2,
+	"compelling":    2,
+	"intuitive":     2,
+	"refreshing":    2,
+	"responsive":    2,
+	"balanced":      2,
+	"atmospheric":   2,
+	"mesmerizing":   3,
+	"mesmerising":   3,
+	"breathtaking":  5,
+	"phenomenal":    4,
+	"spectacular":   4,
+	"marvellous":    3,
+	"marvelous":     3,
+	"satisfied":     2,
+	"pleased":       3,
+	"underrated":    1,
+	"charm":         2,
+	"clean":         2,
+	"hooked":        2,
+	"must":          1,
+	"goty":          4,
+	"replayable":    2,
+	"replayability": 2,
+
+	// Negative
+	"annoyed":           -2,
+	"annoying":          -2,
+	"awful":             -3,
+	"bad":               -3,
+	"boring":            -3,
+	"broken":            -3,
+	"buggy":             -2,
+	"bugs":              -2,
+	"bug":               -2,
+	"cheap":             -2,
+	"clunky":            -2,
+	"confusing":         -2,
+	"crash":             -2,
+	"crashed":           -2,
+	"crashes":           -2,
+	"crap":              -3,
+	"dead":              -3,
+	"disappointed":      -2,
+	"disappointing":     -2,
+	"disaster":          -2,
+	"dislike":           -2,
+	"dull":              -2,
+	"frustrating":       -2,
+	"frustrated":        -2,
+	"garbage":           -3,
+	"glitch":            -2,
+	"glitches":          -2,
+	"glitchy":           -2,
+	"grind":             -1,
+	"grindy":            -2,
+	"hate":              -3,
+	"hated":             -3,
+	"horrible":          -3,
+	"lag":               -2,
+	"laggy":             -2,
+	"lame":              -2,
+	"mediocre":          -2,
+	"mess":              -2,
+	"messy":             -2,
+	"meh":               -1,
+	"overpriced":        -2,
+	"pathetic":          -2,
+	"pointless":         -2,
+	"poor":              -2,
+	"refund":            -2,
+	"repetitive":        -2,
+	"ripoff":            -3,
+	"rubbish":           -3,
+	"sad":               -2,
+	"scam":              -3,
+	"shallow":           -2,
+	"slow":              -1,
+	"stupid":            -2,
+	"sucks":             -3,
+	"terrible":          -3,
+	"tedious":           -2,
+	"trash":             -3,
+	"ugly":              -3,
+	"unfair":            -2,
+	"unfinished":        -2,
+	"unplayable":        -4,
+	"useless":           -2,
+	"waste":             -1,
+	"wasted":            -2,
+	"worse":             -3,
+	"worst":             -3,
+	"wrong":             -2,
+	"angry":             -3,
+	"abysmal":           -4,
+	"atrocious":         -4,
+	"avoid":             -2,
+	"badly":             -3,
+	"bland":             -2,
+	"clumsy":            -2,
+	"cringe":            -2,
+	"difficult":         -1,
+	"dreadful":          -3,
+	"fail":              -2,
+	"failed":            -2,
+	"failure":           -2,
+	"flawed":            -2,
+	"hard":              -1,
+	"hollow":            -2,
+	"junk":              -3,
+	"lacking":           -2,
+	"lazy":              -2,
+	"outdated":          -2,
+	"painful":           -2,
+	"problem":           -2,
+	"problems":          -2,
+	"regret":            -2,
+	"ruined":            -2,
+	"sluggish":          -2,
+	"stutter":           -2,
+	"stuttering":        -2,
+	"tiresome":          -2,
+	"unbalanced":        -2,
+	"unresponsive":      -2,
+	"upset":             -2,
+	"weak":              -2,
+	"paywall":           -2,
+	"microtransactions": -2,
+	"p2w":               -3,
+	"uninstall":         -2,
+	"uninstalled":       -2,
+}
+
+// negations invert the score of the word that follows them.
+var negations = map[string]bool{
+	"ain't":     true,
+	"aren't":    true,
+	"can't":     true,
+	"cannot":    true,
+	"couldn't":  true,
+	"didn't":    true,
+	"doesn't":   true,
+	"don't":     true,
+	"hardly":    true,
+	"isn't":     true,
+	"never":     true,
+	"no":        true,
+	"nor":       true,
+	"not":       true,
+	"shouldn't": true,
+	"wasn't":    true,
+	"weren't":   true,
+	"without":   true,
+	"won't":     true,
+	"wouldn't":  true,
+}
+
+// stopWords are common words which say nothing about what a comment is about.
+var stopWords = map[string]bool{
+	"a": true, "about": true, "above": true, "after": true, "again": true,
+	"against": true, "all": true, "also": true, "am": true, "an": true,
+	"and": true, "any": true, "are": true, "as": true, "at": true,
+	"be": true, "because": true, "been": true, "before": true, "being": true,
+	"below": true, "between": true, "both": true, "but": true, "by": true,
+	"can": true, "could": true, "did": true, "do": true, "does": true,
+	"doing": true, "down": true, "during": true, "each": true, "even": true,
+	"ever": true, "every": true, "few": true, "for": true, "from": true,
+	"further": true, "get": true, "got": true, "had": true, "has": true,
+	"have": true, "having": true, "he": true, "her": true, "here": true,
+	"hers": true, "herself": true, "him": true, "himself": true, "his": true,
+	"how": true, "i": true, "i'm": true, "i've": true, "if": true,
+	"in": true, "into": true, "is": true, "it": true, "it's": true,
+	"its": true, "itself": true, "just": true, "let": true, "me": true,
+	"more": true, "most": true, "much": true, "my": true, "myself": true,
+	"now": true, "of": true, "off": true, "on": true, "once": true,
+	"one": true, "only": true, "or": true, "other": true, "our": true,
+	"ours": true, "ourselves": true, "out": true, "over": true, "own": true,
+	"really": true, "same": true, "she": true, "should": true, "so": true,
+	"some": true, "still": true, "such": true, "than": true, "that": true,
+	"that's": true, "the": true, "their": true, "theirs": true, "them": true,
+	"themselves": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "those": true, "through": true, "to": true, "too": true,
+	"under": true, "until": true, "up": true, "us": true, "very": true,
+	"was": true, "we": true, "were": true, "what": true, "when": true,
+	"where": true, "which": true, "while": true, "who": true, "whom": true,
+	"why": true, "will": true, "with": true, "would": true, "you": true,
+	"you're": true, "your": true, "yours": true, "yourself": true, "yourselves": true,
+	"game": true, "games": true, "play": true, "played": true, "playing": true,
+}
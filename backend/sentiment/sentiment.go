@@ -0,0 +1,70 @@
+// Package sentiment scores the sentiment of short English texts, such as
+// comments, using a bundled word list in the style of AFINN. Each word in the
+// lexicon has a score from -5 (very negative) to 5 (very positive) and a text
+// is scored by summing the scores of its words.
+package sentiment
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Analysis is the result of scoring a text.
+type Analysis struct {
+	// Score is the sum of the scores of every word in the text.
+	Score int
+	// Comparative is Score divided by the number of words, so that long and
+	// short texts can be compared.
+	Comparative float64
+	// Words is the number of words in the text.
+	Words int
+}
+
+// Analyse scores the sentiment of text. A word directly following a negation
+// such as "not" or "never" has its score inverted, so "not good" is negative.
+func Analyse(text string) Analysis {
+	words := Tokenize(text)
+	analysis := Analysis{Words: len(words)}
+
+	negate := false
+	for _, word := range words {
+		score, scored := lexicon[word]
+		if scored {
+			if negate {
+				score = -score
+			}
+			analysis.Score += score
+		}
+
+		negate = negations[word]
+	}
+
+	if analysis.Words > 0 {
+		analysis.Comparative = float64(analysis.Score) / float64(analysis.Words)
+	}
+
+	return analysis
+}
+
+// Tokenize splits text into lower case words. Apostrophes within words are
+// kept so that contractions such as "don't" stay whole.
+func Tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.Trim(field, "'"); field != "" {
+			words = append(words, field)
+		}
+	}
+
+	return words
+}
+
+// IsStopWord reports whether word is too common to be useful as a keyword.
+// word should be lower case, as returned by Tokenize.
+func IsStopWord(word string) bool {
+	return stopWords[word] || negations[word]
+}
@@ -0,0 +1,40 @@
+package sentiment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyse(t *testing.T) {
+	tests := []struct {
+		text  string
+		score int
+		words int
+	}{
+		{text: "", score: 0, words: 0},
+		{text: "Lorem ipsum dolor sit amet.", score: 0, words: 5},
+		{text: "Great game, I love it!", score: 6, words: 5},
+		{text: "Buggy and boring. Crashes constantly", score: -7, words: 5},
+		{text: "Not good, never fun", score: -6, words: 4},
+		{text: "I don't hate it", score: 3, words: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := Analyse(tt.text)
+			assert.Equal(t, tt.score, got.Score)
+			assert.Equal(t, tt.words, got.Words)
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"don't", "stop", "it's", "10", "out", "of", "10"},
+		Tokenize("DON'T stop -- it's 10 out of 10!"))
+}
+
+func TestIsStopWord(t *testing.T) {
+	assert.True(t, IsStopWord("the"))
+	assert.True(t, IsStopWord("not"))
+	assert.False(t, IsStopWord("graphics"))
+}
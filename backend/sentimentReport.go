@@ -0,0 +1,211 @@
+package backend
+
+import (
+	"context"
+	"sort"
+
+	"github.com/DHBosworth/technichalexercise/backend/sentiment"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SectionSentiment is the name of the comment sentiment report section.
+const SectionSentiment = "sentiment"
+
+// topKeywords is the number of keywords listed for each game
+const topKeywords = 10
+
+// minKeywordLength is the length of the shortest word used as a keyword
+const minKeywordLength = 3
+
+// SentimentReport holds the sentiment of comments per game and per user. Games
+// and users are ordered from most negative to most positive so that those
+// getting negative feedback come first.
+type SentimentReport struct {
+	Games []GameSentiment `json:"games"`
+	Users []UserSentiment `json:"users"`
+}
+
+// GameSentiment holds the sentiment of the comments on a game and the words
+// used most in them. A comment is positive if its sentiment score is above
+// zero and negative if it is below.
+type GameSentiment struct {
+	Title            string         `json:"title"`
+	Comments         int            `json:"comments"`
+	AverageSentiment float64        `json:"average_sentiment"`
+	Positive         int            `json:"positive"`
+	Negative         int            `json:"negative"`
+	Keywords         []KeywordCount `json:"keywords"`
+}
+
+// UserSentiment holds the sentiment of the comments a user has made.
+type UserSentiment struct {
+	User             string  `json:"user"`
+	Comments         int     `json:"comments"`
+	AverageSentiment float64 `json:"average_sentiment"`
+}
+
+// KeywordCount is the number of times a keyword was used.
+type KeywordCount struct {
+	Keyword string `json:"keyword"`
+	Count   int    `json:"count"`
+}
+
+type sentimentTotal struct {
+	comments int
+	score    int
+}
+
+func (total sentimentTotal) average() float64 {
+	if total.comments == 0 {
+		return 0
+	}
+
+	return float64(total.score) / float64(total.comments)
+}
+
+// sentimentAccumulator scores comments as games are added to it
+type sentimentAccumulator struct {
+	games []GameSentiment
+	users map[string]*sentimentTotal
+}
+
+func newSentimentAcc() *sentimentAccumulator {
+	return &sentimentAccumulator{
+		games: make([]GameSentiment, 0),
+		users: make(map[string]*sentimentTotal),
+	}
+}
+
+func (acc *sentimentAccumulator) Add(game Game) {
+	var total sentimentTotal
+	stats := GameSentiment{Title: game.Title}
+	keywords := make(map[string]int)
+
+	for _, comment := range game.Comments {
+		score := sentiment.Analyse(comment.Message).Score
+
+		total.comments++
+		total.score += score
+		switch {
+		case score > 0:
+			stats.Positive++
+		case score < 0:
+			stats.Negative++
+		}
+
+		user, ok := acc.users[comment.User]
+		if !ok {
+			user = &sentimentTotal{}
+			acc.users[comment.User] = user
+		}
+		user.comments++
+		user.score += score
+
+		for _, word := range sentiment.Tokenize(comment.Message) {
+			if len(word) >= minKeywordLength && !sentiment.IsStopWord(word) {
+				keywords[word]++
+			}
+		}
+	}
+
+	stats.Comments = total.comments
+	stats.AverageSentiment = total.average()
+	stats.Keywords = topKeywordCounts(keywords, topKeywords)
+
+	acc.games = append(acc.games, stats)
+}
+
+func (acc *sentimentAccumulator) Result() interface{} {
+	report := SentimentReport{
+		Games: acc.games,
+		Users: make([]UserSentiment, 0, len(acc.users)),
+	}
+
+	for name, total := range acc.users {
+		report.Users = append(report.Users, UserSentiment{
+			User:             name,
+			Comments:         total.comments,
+			AverageSentiment: total.average(),
+		})
+	}
+
+	sort.Slice(report.Games, func(i, j int) bool {
+		a, b := report.Games[i], report.Games[j]
+		if a.AverageSentiment != b.AverageSentiment {
+			return a.AverageSentiment < b.AverageSentiment
+		}
+		return a.Title < b.Title
+	})
+
+	sort.Slice(report.Users, func(i, j int) bool {
+		a, b := report.Users[i], report.Users[j]
+		if a.AverageSentiment != b.AverageSentiment {
+			return a.AverageSentiment < b.AverageSentiment
+		}
+		return a.User < b.User
+	})
+
+	return report
+}
+
+// topKeywordCounts returns the n most used keywords, breaking ties
+// alphabetically
+func topKeywordCounts(counts map[string]int, n int) []KeywordCount {
+	keywords := make([]KeywordCount, 0, len(counts))
+	for keyword, count := range counts {
+		keywords = append(keywords, KeywordCount{Keyword: keyword, Count: count})
+	}
+
+	sort.Slice(keywords, func(i, j int) bool {
+		if keywords[i].Count != keywords[j].Count {
+			return keywords[i].Count > keywords[j].Count
+		}
+		return keywords[i].Keyword < keywords[j].Keyword
+	})
+
+	if len(keywords) > n {
+		keywords = keywords[:n]
+	}
+
+	return keywords
+}
+
+// sentimentPipeline fetches only the parts of each game needed to score its
+// comments. Scoring happens as the games are decoded.
+func sentimentPipeline() []bson.D {
+	projectComments := bson.D{
+		{
+			"$project", bson.D{
+				{"title", 1},
+				{"comments.user", 1},
+				{"comments.message", 1},
+			},
+		},
+	}
+
+	return []bson.D{
+		projectComments,
+	}
+}
+
+func init() {
+	RegisterReportSection(ReportSection{
+		Name:     SectionSentiment,
+		Pipeline: sentimentPipeline,
+		Decode: func(ctx context.Context, cur SectionCursor) (interface{}, error) {
+			acc := newSentimentAcc()
+			for cur.Next(ctx) {
+				var game Game
+				if err := cur.Decode(&game); err != nil {
+					return nil, err
+				}
+				acc.Add(game)
+			}
+
+			return acc.Result(), cur.Err()
+		},
+		Accumulator: func() SectionAccumulator {
+			return newSentimentAcc()
+		},
+	})
+}
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSentimentSection(t *testing.T) {
+	mem := NewMemoryDataSource(map[string]Game{
+		"1": {
+			Title: "Loved",
+			Comments: []Comment{
+				{User: "a", Message: "Amazing graphics and great story"},
+				{User: "b", Message: "The story is wonderful, graphics too"},
+			},
+		},
+		"2": {
+			Title: "Broken",
+			Comments: []Comment{
+				{User: "a", Message: "Crashes on launch, terrible"},
+				{User: "c", Message: "Lorem ipsum"},
+			},
+		},
+	})
+
+	sections, err := mem.ReportSections(context.Background(), []string{SectionSentiment})
+	assert.NoError(t, err)
+
+	report := sections[SectionSentiment].(SentimentReport)
+	assert.Equal(t, []GameSentiment{
+		{
+			Title:            "Broken",
+			Comments:         2,
+			AverageSentiment: -2.5,
+			Negative:         1,
+			Keywords: []KeywordCount{
+				{Keyword: "crashes", Count: 1},
+				{Keyword: "ipsum", Count: 1},
+				{Keyword: "launch", Count: 1},
+				{Keyword: "lorem", Count: 1},
+				{Keyword: "terrible", Count: 1},
+			},
+		},
+		{
+			Title:            "Loved",
+			Comments:         2,
+			AverageSentiment: 5.5,
+			Positive:         2,
+			Keywords: []KeywordCount{
+				{Keyword: "graphics", Count: 2},
+				{Keyword: "story", Count: 2},
+				{Keyword: "amazing", Count: 1},
+				{Keyword: "great", Count: 1},
+				{Keyword: "wonderful", Count: 1},
+			},
+		},
+	}, report.Games)
+
+	assert.Equal(t, []UserSentiment{
+		{User: "c", Comments: 1, AverageSentiment: 0},
+		{User: "a", Comments: 2, AverageSentiment: 1},
+		{User: "b", Comments: 1, AverageSentiment: 4},
+	}, report.Users)
+}
@@ -0,0 +1,187 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The kinds of reason a game can be similar to another.
+const (
+	ReasonPublisher  = "publisher"
+	ReasonPlatforms  = "platforms"
+	ReasonAgeRating  = "age_rating"
+	ReasonCommenters = "commenters"
+)
+
+// The weight of each kind of reason in the score of a similar game. A game
+// by the same publisher, on exactly the same platforms, in the same age
+// rating band and commented on by exactly the same users scores 1.
+const (
+	publisherWeight  = 0.3
+	platformsWeight  = 0.25
+	ageRatingWeight  = 0.15
+	commentersWeight = 0.3
+)
+
+// SimilarGame is a game recommended because of its similarity to another.
+// Score is between 0 and 1, with the reasons explaining how it was reached.
+type SimilarGame struct {
+	ID      string             `json:"id"`
+	Title   string             `json:"title"`
+	Score   float64            `json:"score"`
+	Reasons []SimilarityReason `json:"reasons"`
+}
+
+// SimilarityReason explains part of the score of a SimilarGame.
+type SimilarityReason struct {
+	Kind   string  `json:"kind"`
+	Detail string  `json:"detail"`
+	Score  float64 `json:"score"`
+}
+
+// similarity scores candidate against game. Only candidates sharing a
+// publisher, platform or commenter with game are similar, a matching age
+// rating alone isn't enough as so many games share one.
+func similarity(game, candidate Game) (similar SimilarGame, ok bool) {
+	similar = SimilarGame{
+		ID:      candidate.ID,
+		Title:   candidate.Title,
+		Reasons: make([]SimilarityReason, 0),
+	}
+
+	if game.By != "" && game.By == candidate.By {
+		similar.Reasons = append(similar.Reasons, SimilarityReason{
+			Kind:   ReasonPublisher,
+			Detail: fmt.Sprintf("Also by %s", candidate.By),
+			Score:  publisherWeight,
+		})
+	}
+
+	if shared, overlap := jaccard(game.Platform, candidate.Platform); len(shared) > 0 {
+		similar.Reasons = append(similar.Reasons, SimilarityReason{
+			Kind:   ReasonPlatforms,
+			Detail: fmt.Sprintf("Also on %s", strings.Join(shared, ", ")),
+			Score:  platformsWeight * overlap,
+		})
+	}
+
+	if shared, overlap := jaccard(commenters(game), commenters(candidate)); len(shared) > 0 {
+		detail := fmt.Sprintf("%d commenters in common", len(shared))
+		if len(shared) == 1 {
+			detail = "1 commenter in common"
+		}
+
+		similar.Reasons = append(similar.Reasons, SimilarityReason{
+			Kind:   ReasonCommenters,
+			Detail: detail,
+			Score:  commentersWeight * overlap,
+		})
+	}
+
+	if len(similar.Reasons) == 0 {
+		return similar, false
+	}
+
+	if game.AgeRating.Valid && candidate.AgeRating.Valid {
+		band, _ := ageBand(game.AgeRating)
+		if candidateBand, _ := ageBand(candidate.AgeRating); band == candidateBand {
+			similar.Reasons = append(similar.Reasons, SimilarityReason{
+				Kind:   ReasonAgeRating,
+				Detail: fmt.Sprintf("Also rated %s", band),
+				Score:  ageRatingWeight,
+			})
+		}
+	}
+
+	for _, reason := range similar.Reasons {
+		similar.Score += reason.Score
+	}
+
+	return similar, true
+}
+
+// commenters lists the users who commented on game
+func commenters(game Game) []string {
+	users := make([]string, len(game.Comments))
+	for i, comment := range game.Comments {
+		users[i] = comment.User
+	}
+
+	return users
+}
+
+// jaccard returns the values found in both a and b, sorted, along with the
+// size of that intersection relative to their union
+func jaccard(a, b []string) (shared []string, overlap float64) {
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[v] = true
+	}
+
+	union := len(inA)
+	seen := make(map[string]bool, len(b))
+	for _, v := range b {
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+
+		if inA[v] {
+			shared = append(shared, v)
+		} else {
+			union++
+		}
+	}
+
+	if len(shared) == 0 {
+		return nil, 0
+	}
+
+	sort.Strings(shared)
+	return shared, float64(len(shared)) / float64(union)
+}
+
+// similarAccumulator keeps the games most similar to a game as candidates
+// are added to it
+type similarAccumulator struct {
+	game    Game
+	limit   int
+	similar []SimilarGame
+}
+
+func newSimilarAcc(game Game, limit int) *similarAccumulator {
+	return &similarAccumulator{
+		game:    game,
+		limit:   limit,
+		similar: make([]SimilarGame, 0),
+	}
+}
+
+func (acc *similarAccumulator) processGame(candidate Game) {
+	if candidate.ID == acc.game.ID {
+		return
+	}
+
+	if similar, ok := similarity(acc.game, candidate); ok {
+		acc.similar = append(acc.similar, similar)
+	}
+}
+
+// result lists the similar games, most similar first. Ties are broken by
+// title so the order is stable.
+func (acc *similarAccumulator) result() []SimilarGame {
+	sort.Slice(acc.similar, func(i, j int) bool {
+		a, b := acc.similar[i], acc.similar[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Title < b.Title
+	})
+
+	if acc.limit > 0 && len(acc.similar) > acc.limit {
+		acc.similar = acc.similar[:acc.limit]
+	}
+
+	return acc.similar
+}
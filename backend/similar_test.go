@@ -0,0 +1,75 @@
+package backend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDataSource_SimilarGames(t *testing.T) {
+	mem := NewMemoryDataSource(map[string]Game{
+		"1": {
+			Title:     "Original",
+			By:        "Studio",
+			Platform:  []string{"PC", "XBOX"},
+			AgeRating: MustParseAgeRating("PEGI 12"),
+			Comments:  []Comment{{User: "a"}, {User: "b"}},
+		},
+		"2": {
+			Title:     "Sequel",
+			By:        "Studio",
+			Platform:  []string{"PC", "XBOX"},
+			AgeRating: MustParseAgeRating("ESRB E10+"),
+			Comments:  []Comment{{User: "a"}, {User: "c"}},
+		},
+		"3": {
+			Title:     "Port",
+			By:        "Someone else",
+			Platform:  []string{"PC"},
+			AgeRating: MustParseAgeRating("PEGI 12"),
+		},
+		"4": {
+			Title:     "Unrelated",
+			By:        "Someone else",
+			Platform:  []string{"Switch"},
+			AgeRating: MustParseAgeRating("PEGI 12"),
+		},
+	})
+
+	// One of the three commenters is shared, calculated at run time to match
+	// the rounding of the scores
+	third := 1.0 / 3
+
+	similar, err := mem.SimilarGames(context.Background(), "1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []SimilarGame{
+		{
+			ID:    "2",
+			Title: "Sequel",
+			Score: publisherWeight + platformsWeight + commentersWeight*third + ageRatingWeight,
+			Reasons: []SimilarityReason{
+				{Kind: ReasonPublisher, Detail: "Also by Studio", Score: publisherWeight},
+				{Kind: ReasonPlatforms, Detail: "Also on PC, XBOX", Score: platformsWeight},
+				{Kind: ReasonCommenters, Detail: "1 commenter in common", Score: commentersWeight * third},
+				{Kind: ReasonAgeRating, Detail: "Also rated 12+", Score: ageRatingWeight},
+			},
+		},
+		{
+			ID:    "3",
+			Title: "Port",
+			Score: platformsWeight/2 + ageRatingWeight,
+			Reasons: []SimilarityReason{
+				{Kind: ReasonPlatforms, Detail: "Also on PC", Score: platformsWeight / 2},
+				{Kind: ReasonAgeRating, Detail: "Also rated 12+", Score: ageRatingWeight},
+			},
+		},
+	}, similar, "A matching age rating alone shouldn't make a game similar")
+
+	similar, err = mem.SimilarGames(context.Background(), "1", 1)
+	assert.NoError(t, err)
+	assert.Len(t, similar, 1)
+
+	_, err = mem.SimilarGames(context.Background(), "5", 0)
+	assert.Error(t, err, "Unknown games should be an error")
+}
@@ -0,0 +1,212 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/logging"
+)
+
+// ErrSnapshotNotFound is returned by a ReportStore when there is no snapshot
+// for the requested time.
+var ErrSnapshotNotFound = errors.New("No report snapshot found")
+
+// ReportSnapshot is a report persisted at a point in time.
+type ReportSnapshot struct {
+	TakenAt time.Time `json:"taken_at" bson:"taken_at"`
+	Report  Report    `json:"report" bson:"report"`
+}
+
+// ReportStore represents any type which can persist report snapshots.
+type ReportStore interface {
+	// SaveReport persists a snapshot.
+	SaveReport(ctx context.Context, snapshot ReportSnapshot) error
+	// ReportHistory returns the snapshots taken between from and to inclusive,
+	// oldest first.
+	ReportHistory(ctx context.Context, from, to time.Time) ([]ReportSnapshot, error)
+	// ReportAt returns the latest snapshot taken at or before t, or
+	// ErrSnapshotNotFound if there isn't one.
+	ReportAt(ctx context.Context, t time.Time) (ReportSnapshot, error)
+}
+
+// storedDataSource pairs a data source with a separate report store.
+type storedDataSource struct {
+	ServiceDataSource
+	ReportStore
+}
+
+// WithReportStore combines a data source with a report store so that report
+// history can be served for data sources that can't store reports themselves.
+// The result is a HealthChecker checking both, and closes ds when closed.
+func WithReportStore(ds ServiceDataSource, store ReportStore) ServiceDataSource {
+	return storedDataSource{
+		ServiceDataSource: ds,
+		ReportStore:       store,
+	}
+}
+
+// CheckHealth checks the data source and the report store, when they can be
+// checked
+func (stored storedDataSource) CheckHealth(ctx context.Context) error {
+	if checker, ok := stored.ServiceDataSource.(HealthChecker); ok {
+		if err := checker.CheckHealth(ctx); err != nil {
+			return err
+		}
+	}
+
+	if checker, ok := stored.ReportStore.(HealthChecker); ok {
+		return checker.CheckHealth(ctx)
+	}
+
+	return nil
+}
+
+// Close closes the data source, if it can be closed
+func (stored storedDataSource) Close() error {
+	if closer, ok := stored.ServiceDataSource.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// SnapshotReports takes a report from ds and saves it to store every interval
+// until ctx is done. The first snapshot is taken immediately. Each snapshot
+// must be taken within interval, so that one which hangs doesn't hold up the
+// rest. Failures are logged and retried at the next interval.
+func SnapshotReports(ctx context.Context, ds GameDataSource, store ReportStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, interval)
+		snapshotReport(runCtx, ds, store)
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func snapshotReport(ctx context.Context, ds GameDataSource, store ReportStore) {
+	report, err := ds.Report(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Unable to create report snapshot: %v", err)
+		return
+	}
+
+	snapshot := ReportSnapshot{
+		TakenAt: time.Now().UTC(),
+		Report:  report,
+	}
+
+	if err := store.SaveReport(ctx, snapshot); err != nil {
+		logging.FromContext(ctx).Warnf("Unable to save report snapshot: %v", err)
+		return
+	}
+
+	logging.FromContext(ctx).Debugf("Saved report snapshot taken at %v", snapshot.TakenAt)
+}
+
+// ReportDiff describes how a report changed between two snapshots.
+type ReportDiff struct {
+	From                 time.Time              `json:"from"`
+	To                   time.Time              `json:"to"`
+	UserWithMostComments StringChange           `json:"user_with_most_comments"`
+	HighestRatedGame     StringChange           `json:"highest_rated_game"`
+	AverageLikesPerGame  []GameAverageLikesDiff `json:"average_likes_per_game"`
+}
+
+// StringChange holds the old and new value of a field in a report.
+type StringChange struct {
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Changed bool   `json:"changed"`
+}
+
+// The status of a game in a GameAverageLikesDiff.
+const (
+	DiffAdded     = "added"
+	DiffRemoved   = "removed"
+	DiffChanged   = "changed"
+	DiffUnchanged = "unchanged"
+)
+
+// GameAverageLikesDiff holds the change in the average likes of a game. From
+// is nil for games which were added and To is nil for games which were
+// removed.
+type GameAverageLikesDiff struct {
+	Title  string   `json:"title"`
+	From   *float64 `json:"from"`
+	To     *float64 `json:"to"`
+	Change float64  `json:"change"`
+	Status string   `json:"status"`
+}
+
+func newStringChange(from, to string) StringChange {
+	return StringChange{
+		From:    from,
+		To:      to,
+		Changed: from != to,
+	}
+}
+
+// DiffReports compares the leaders and per game averages of two snapshots.
+func DiffReports(from, to ReportSnapshot) ReportDiff {
+	diff := ReportDiff{
+		From:                 from.TakenAt,
+		To:                   to.TakenAt,
+		UserWithMostComments: newStringChange(from.Report.UserWithMostComments, to.Report.UserWithMostComments),
+		HighestRatedGame:     newStringChange(from.Report.HighestRatedGame, to.Report.HighestRatedGame),
+		AverageLikesPerGame:  make([]GameAverageLikesDiff, 0),
+	}
+
+	games := make(map[string]*GameAverageLikesDiff)
+	gameDiff := func(title string) *GameAverageLikesDiff {
+		d, ok := games[title]
+		if !ok {
+			d = &GameAverageLikesDiff{Title: title}
+			games[title] = d
+		}
+		return d
+	}
+
+	for _, stats := range from.Report.AverageLikesPerGame {
+		avg := stats.AverageLikes
+		gameDiff(stats.Title).From = &avg
+	}
+	for _, stats := range to.Report.AverageLikesPerGame {
+		avg := stats.AverageLikes
+		gameDiff(stats.Title).To = &avg
+	}
+
+	for _, d := range games {
+		switch {
+		case d.From == nil:
+			d.Status = DiffAdded
+			d.Change = *d.To
+		case d.To == nil:
+			d.Status = DiffRemoved
+			d.Change = -*d.From
+		default:
+			d.Change = *d.To - *d.From
+			d.Status = DiffUnchanged
+			if d.Change != 0 {
+				d.Status = DiffChanged
+			}
+		}
+
+		diff.AverageLikesPerGame = append(diff.AverageLikesPerGame, *d)
+	}
+
+	sort.Slice(diff.AverageLikesPerGame, func(i, j int) bool {
+		return diff.AverageLikesPerGame[i].Title < diff.AverageLikesPerGame[j].Title
+	})
+
+	return diff
+}
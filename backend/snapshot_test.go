@@ -0,0 +1,147 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffReports(t *testing.T) {
+	from := ReportSnapshot{
+		TakenAt: mustDate("2020-05-18"),
+		Report: Report{
+			UserWithMostComments: "a",
+			HighestRatedGame:     "First",
+			AverageLikesPerGame: []GameAverageLikes{
+				{Title: "First", AverageLikes: 2},
+				{Title: "Gone", AverageLikes: 1},
+				{Title: "Steady", AverageLikes: 3},
+			},
+		},
+	}
+	to := ReportSnapshot{
+		TakenAt: mustDate("2020-05-25"),
+		Report: Report{
+			UserWithMostComments: "b",
+			HighestRatedGame:     "First",
+			AverageLikesPerGame: []GameAverageLikes{
+				{Title: "First", AverageLikes: 2.5},
+				{Title: "New", AverageLikes: 4},
+				{Title: "Steady", AverageLikes: 3},
+			},
+		},
+	}
+
+	f := func(v float64) *float64 { return &v }
+	assert.Equal(t, ReportDiff{
+		From:                 from.TakenAt,
+		To:                   to.TakenAt,
+		UserWithMostComments: StringChange{From: "a", To: "b", Changed: true},
+		HighestRatedGame:     StringChange{From: "First", To: "First"},
+		AverageLikesPerGame: []GameAverageLikesDiff{
+			{Title: "First", From: f(2), To: f(2.5), Change: 0.5, Status: DiffChanged},
+			{Title: "Gone", From: f(1), Change: -1, Status: DiffRemoved},
+			{Title: "New", To: f(4), Change: 4, Status: DiffAdded},
+			{Title: "Steady", From: f(3), To: f(3), Status: DiffUnchanged},
+		},
+	}, DiffReports(from, to))
+}
+
+func TestFileReportStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileReportStore(dir)
+	if err != nil {
+		t.Fatalf("Unable to create store: %v", err)
+	}
+	ctx := context.Background()
+
+	monday := ReportSnapshot{
+		TakenAt: mustDate("2020-05-18").Add(9 * time.Hour),
+		Report:  Report{HighestRatedGame: "Monday"},
+	}
+	tuesday := ReportSnapshot{
+		TakenAt: mustDate("2020-05-19").Add(9 * time.Hour),
+		Report:  Report{HighestRatedGame: "Tuesday"},
+	}
+	assert.NoError(t, store.SaveReport(ctx, tuesday))
+	assert.NoError(t, store.SaveReport(ctx, monday))
+
+	history, err := store.ReportHistory(ctx, time.Time{}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []ReportSnapshot{monday, tuesday}, history)
+
+	history, err = store.ReportHistory(ctx, mustDate("2020-05-19"), time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, []ReportSnapshot{tuesday}, history)
+
+	snapshot, err := store.ReportAt(ctx, mustDate("2020-05-19"))
+	assert.NoError(t, err)
+	assert.Equal(t, monday, snapshot)
+
+	_, err = store.ReportAt(ctx, mustDate("2020-05-17"))
+	assert.Equal(t, ErrSnapshotNotFound, err)
+}
+
+type checkerFunc func(ctx context.Context) error
+
+func (fn checkerFunc) CheckHealth(ctx context.Context) error {
+	return fn(ctx)
+}
+
+type checkedStore struct {
+	ReportStore
+	checkerFunc
+}
+
+func TestWithReportStore_CheckHealth(t *testing.T) {
+	failing := errors.New("store unavailable")
+	memory := NewMemoryDataSource(nil)
+
+	healthy := WithReportStore(memory, checkedStore{checkerFunc: func(ctx context.Context) error { return nil }})
+	checker, ok := healthy.(HealthChecker)
+	if assert.True(t, ok, "Combined data source should be a HealthChecker") {
+		assert.NoError(t, checker.CheckHealth(context.Background()))
+	}
+
+	unhealthy := WithReportStore(memory, checkedStore{checkerFunc: func(ctx context.Context) error { return failing }})
+	assert.Equal(t, failing, unhealthy.(HealthChecker).CheckHealth(context.Background()), "Store's health should be checked")
+}
+
+// hangingDataSource takes reports which never finish until their context is
+// done
+type hangingDataSource struct {
+	*MemoryDataSource
+	calls chan struct{}
+}
+
+func (ds hangingDataSource) Report(ctx context.Context) (Report, error) {
+	ds.calls <- struct{}{}
+	<-ctx.Done()
+	return Report{}, ctx.Err()
+}
+
+func TestSnapshotReports_timeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ds := hangingDataSource{MemoryDataSource: NewMemoryDataSource(nil), calls: make(chan struct{}, 10)}
+	go SnapshotReports(ctx, ds, nil, 10*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ds.calls:
+		case <-time.After(time.Second):
+			t.Fatalf("Snapshot %d wasn't taken, a hung snapshot should time out", i+1)
+		}
+	}
+}
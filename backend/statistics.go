@@ -0,0 +1,164 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// newGameAverageLikes calculates the like statistics for a game from the likes
+// given to each of its comments. A game without comments has all statistics
+// set to zero.
+func newGameAverageLikes(title string, likes []int) GameAverageLikes {
+	stats := GameAverageLikes{
+		Title:    title,
+		Comments: len(likes),
+	}
+
+	if len(likes) == 0 {
+		return stats
+	}
+
+	sorted := make([]int, len(likes))
+	copy(sorted, likes)
+	sort.Ints(sorted)
+
+	for _, like := range sorted {
+		stats.TotalLikes += like
+	}
+
+	stats.MinLikes = sorted[0]
+	stats.MaxLikes = sorted[len(sorted)-1]
+	stats.AverageLikes = float64(stats.TotalLikes) / float64(len(sorted))
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		stats.MedianLikes = float64(sorted[middle-1]+sorted[middle]) / 2
+	} else {
+		stats.MedianLikes = float64(sorted[middle])
+	}
+
+	var squares float64
+	for _, like := range sorted {
+		diff := float64(like) - stats.AverageLikes
+		squares += diff * diff
+	}
+	stats.StdDevLikes = math.Sqrt(squares / float64(len(sorted)))
+
+	return stats
+}
+
+// Rounding controls how the averages in a report are presented.
+type Rounding string
+
+// The supported rounding modes.
+const (
+	RoundNone    Rounding = "none"
+	RoundCeil    Rounding = "ceil"
+	RoundFloor   Rounding = "floor"
+	RoundNearest Rounding = "nearest"
+)
+
+// ParseRounding converts s into a Rounding mode. An empty string is treated as
+// RoundNone so that averages are exact unless asked otherwise.
+func ParseRounding(s string) (Rounding, error) {
+	switch r := Rounding(s); r {
+	case "":
+		return RoundNone, nil
+	case RoundNone, RoundCeil, RoundFloor, RoundNearest:
+		return r, nil
+	}
+
+	return RoundNone, fmt.Errorf("Invalid rounding %q, expected one of %s, %s, %s or %s",
+		s, RoundNone, RoundCeil, RoundFloor, RoundNearest)
+}
+
+// Apply rounds v according to the rounding mode
+func (r Rounding) Apply(v float64) float64 {
+	switch r {
+	case RoundCeil:
+		return math.Ceil(v)
+	case RoundFloor:
+		return math.Floor(v)
+	case RoundNearest:
+		return math.Round(v)
+	}
+
+	return v
+}
+
+// Rounded returns a copy of the report with the average and median likes of
+// every game rounded using mode.
+func (report Report) Rounded(mode Rounding) Report {
+	if mode == RoundNone || report.AverageLikesPerGame == nil {
+		return report
+	}
+
+	rounded := make([]GameAverageLikes, len(report.AverageLikesPerGame))
+	for i, stats := range report.AverageLikesPerGame {
+		rounded[i] = stats.Rounded(mode)
+	}
+	report.AverageLikesPerGame = rounded
+
+	return report
+}
+
+// Rounded returns a copy of stats with the average and median likes rounded
+// using mode.
+func (stats GameAverageLikes) Rounded(mode Rounding) GameAverageLikes {
+	stats.AverageLikes = mode.Apply(stats.AverageLikes)
+	stats.MedianLikes = mode.Apply(stats.MedianLikes)
+
+	return stats
+}
+
+// newGameStats calculates the statistics for a single game, listing at most top
+// of its most active commenters.
+func newGameStats(game Game, top int) GameStats {
+	stats := GameStats{
+		GameAverageLikes: processLikes(game),
+		TopCommenters:    make([]CommenterStats, 0),
+	}
+
+	commenters := make(map[string]*CommenterStats)
+	for _, comment := range game.Comments {
+		commenter, ok := commenters[comment.User]
+		if !ok {
+			commenter = &CommenterStats{User: comment.User}
+			commenters[comment.User] = commenter
+		}
+		commenter.Comments++
+		commenter.Likes += comment.Like
+
+		date := comment.DateCreated
+		if stats.FirstComment == nil || time.Time(date).Before(time.Time(*stats.FirstComment)) {
+			stats.FirstComment = &date
+		}
+		if stats.LastComment == nil || time.Time(date).After(time.Time(*stats.LastComment)) {
+			stats.LastComment = &date
+		}
+	}
+	stats.UniqueCommenters = len(commenters)
+
+	for _, commenter := range commenters {
+		stats.TopCommenters = append(stats.TopCommenters, *commenter)
+	}
+
+	sort.Slice(stats.TopCommenters, func(i, j int) bool {
+		a, b := stats.TopCommenters[i], stats.TopCommenters[j]
+		if a.Comments != b.Comments {
+			return a.Comments > b.Comments
+		}
+		if a.Likes != b.Likes {
+			return a.Likes > b.Likes
+		}
+		return a.User < b.User
+	})
+
+	if len(stats.TopCommenters) > top {
+		stats.TopCommenters = stats.TopCommenters[:top]
+	}
+
+	return stats
+}
@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newGameAverageLikes(t *testing.T) {
+	tests := []struct {
+		name  string
+		likes []int
+		want  GameAverageLikes
+	}{
+		{
+			name:  "No comments",
+			likes: nil,
+			want:  GameAverageLikes{Title: "Game"},
+		},
+		{
+			name:  "Odd number of comments",
+			likes: []int{5, 1, 3},
+			want: GameAverageLikes{
+				Title:        "Game",
+				Comments:     3,
+				TotalLikes:   9,
+				AverageLikes: 3,
+				MedianLikes:  3,
+				MinLikes:     1,
+				MaxLikes:     5,
+				StdDevLikes:  1.632993161855452,
+			},
+		},
+		{
+			name:  "Even number of comments",
+			likes: []int{1, 1, 2, 0},
+			want: GameAverageLikes{
+				Title:        "Game",
+				Comments:     4,
+				TotalLikes:   4,
+				AverageLikes: 1,
+				MedianLikes:  1,
+				MinLikes:     0,
+				MaxLikes:     2,
+				StdDevLikes:  0.7071067811865476,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, newGameAverageLikes("Game", tt.likes))
+		})
+	}
+}
+
+func TestParseRounding(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rounding
+		wantErr bool
+	}{
+		{in: "", want: RoundNone},
+		{in: "none", want: RoundNone},
+		{in: "ceil", want: RoundCeil},
+		{in: "floor", want: RoundFloor},
+		{in: "nearest", want: RoundNearest},
+		{in: "up", want: RoundNone, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseRounding(tt.in)
+			assert.Equal(t, tt.wantErr, err != nil, "Unexpected error: %v", err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
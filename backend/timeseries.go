@@ -0,0 +1,131 @@
+package backend
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Interval is the width of the buckets in a TimeSeries.
+type Interval string
+
+// The supported time series intervals.
+const (
+	IntervalDay   Interval = "day"
+	IntervalWeek  Interval = "week"
+	IntervalMonth Interval = "month"
+)
+
+// ParseInterval converts s into an Interval. An empty string is treated as
+// IntervalDay.
+func ParseInterval(s string) (Interval, error) {
+	switch i := Interval(s); i {
+	case "":
+		return IntervalDay, nil
+	case IntervalDay, IntervalWeek, IntervalMonth:
+		return i, nil
+	}
+
+	return IntervalDay, fmt.Errorf("Invalid interval %q, expected one of %s, %s or %s",
+		s, IntervalDay, IntervalWeek, IntervalMonth)
+}
+
+// Truncate returns the start of the bucket t falls in. Buckets are calculated
+// in UTC and weeks start on a Monday, matching the $dateTrunc stage used by
+// MongoDataSource.
+func (i Interval) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	year, month, day := t.Date()
+
+	switch i {
+	case IntervalWeek:
+		daysSinceMonday := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, time.UTC)
+	case IntervalMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// timeSeriesAccumulator collects comment activity per game and bucket so that
+// it can be turned into a TimeSeries.
+type timeSeriesAccumulator struct {
+	interval Interval
+	games    map[string]map[time.Time]*TimeBucket
+	overall  map[time.Time]*TimeBucket
+}
+
+func newTimeSeriesAcc(interval Interval) *timeSeriesAccumulator {
+	return &timeSeriesAccumulator{
+		interval: interval,
+		games:    make(map[string]map[time.Time]*TimeBucket),
+		overall:  make(map[time.Time]*TimeBucket),
+	}
+}
+
+// add records activity for the game with the given title in the bucket
+// starting at start.
+func (acc *timeSeriesAccumulator) add(title string, start time.Time, comments, likes int) {
+	buckets, ok := acc.games[title]
+	if !ok {
+		buckets = make(map[time.Time]*TimeBucket)
+		acc.games[title] = buckets
+	}
+
+	addToBucket(buckets, start, comments, likes)
+	addToBucket(acc.overall, start, comments, likes)
+}
+
+func (acc *timeSeriesAccumulator) processGame(game Game) {
+	for _, comment := range game.Comments {
+		start := acc.interval.Truncate(time.Time(comment.DateCreated))
+		acc.add(game.Title, start, 1, comment.Like)
+	}
+}
+
+func (acc *timeSeriesAccumulator) timeSeries() TimeSeries {
+	series := TimeSeries{
+		Interval: acc.interval,
+		Overall:  sortedBuckets(acc.overall),
+		Games:    make([]GameTimeSeries, 0, len(acc.games)),
+	}
+
+	for title, buckets := range acc.games {
+		series.Games = append(series.Games, GameTimeSeries{
+			Title:   title,
+			Buckets: sortedBuckets(buckets),
+		})
+	}
+
+	sort.Slice(series.Games, func(i, j int) bool {
+		return series.Games[i].Title < series.Games[j].Title
+	})
+
+	return series
+}
+
+func addToBucket(buckets map[time.Time]*TimeBucket, start time.Time, comments, likes int) {
+	start = start.UTC()
+	bucket, ok := buckets[start]
+	if !ok {
+		bucket = &TimeBucket{Start: EpochToReadable(start)}
+		buckets[start] = bucket
+	}
+
+	bucket.Comments += comments
+	bucket.Likes += likes
+}
+
+func sortedBuckets(buckets map[time.Time]*TimeBucket) []TimeBucket {
+	sorted := make([]TimeBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		sorted = append(sorted, *bucket)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return time.Time(sorted[i].Start).Before(time.Time(sorted[j].Start))
+	})
+
+	return sorted
+}
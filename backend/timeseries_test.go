@@ -0,0 +1,76 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustDate(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic("mustDate: " + err.Error())
+	}
+
+	return t
+}
+
+func TestInterval_Truncate(t *testing.T) {
+	tests := []struct {
+		interval Interval
+		in       string
+		want     string
+	}{
+		{interval: IntervalDay, in: "2020-05-20", want: "2020-05-20"},
+		{interval: IntervalWeek, in: "2020-05-20", want: "2020-05-18"},
+		{interval: IntervalWeek, in: "2020-05-18", want: "2020-05-18"},
+		{interval: IntervalWeek, in: "2020-05-24", want: "2020-05-18"},
+		{interval: IntervalMonth, in: "2020-05-20", want: "2020-05-01"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.interval)+" "+tt.in, func(t *testing.T) {
+			in := mustDate(tt.in).Add(13 * time.Hour)
+			assert.Equal(t, mustDate(tt.want), tt.interval.Truncate(in))
+		})
+	}
+}
+
+func TestMemoryDataSource_TimeSeries(t *testing.T) {
+	mem := NewMemoryDataSource(map[string]Game{
+		"1": {
+			Title: "First",
+			Comments: []Comment{
+				{User: "a", DateCreated: EpochToReadable(mustDate("2020-05-18")), Like: 1},
+				{User: "b", DateCreated: EpochToReadable(mustDate("2020-05-24")), Like: 2},
+				{User: "c", DateCreated: EpochToReadable(mustDate("2020-05-25")), Like: 3},
+			},
+		},
+		"2": {
+			Title: "Second",
+			Comments: []Comment{
+				{User: "a", DateCreated: EpochToReadable(mustDate("2020-05-19")), Like: 4},
+			},
+		},
+		"3": {Title: "Silent"},
+	})
+
+	series, err := mem.TimeSeries(context.Background(), IntervalWeek)
+	assert.NoError(t, err)
+
+	week := func(s string, comments, likes int) TimeBucket {
+		return TimeBucket{Start: EpochToReadable(mustDate(s)), Comments: comments, Likes: likes}
+	}
+	assert.Equal(t, TimeSeries{
+		Interval: IntervalWeek,
+		Overall: []TimeBucket{
+			week("2020-05-18", 3, 7),
+			week("2020-05-25", 1, 3),
+		},
+		Games: []GameTimeSeries{
+			{Title: "First", Buckets: []TimeBucket{week("2020-05-18", 2, 3), week("2020-05-25", 1, 3)}},
+			{Title: "Second", Buckets: []TimeBucket{week("2020-05-18", 1, 4)}},
+		},
+	}, series)
+}
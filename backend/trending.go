@@ -0,0 +1,220 @@
+package backend
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TrendingOptions controls how games are ranked by Trending. Only comments
+// made within Window before Now are counted, and each counts for half as much
+// for every HalfLife that has passed since it was made.
+type TrendingOptions struct {
+	// Now is the end of the window, the zero value meaning the current time.
+	Now      time.Time
+	Window   time.Duration
+	HalfLife time.Duration
+	// Limit is the number of games listed, zero listing every game with a
+	// comment in the window.
+	Limit int
+}
+
+// Trending ranks games by how much attention they have had recently.
+type Trending struct {
+	From  time.Time      `json:"from"`
+	To    time.Time      `json:"to"`
+	Games []TrendingGame `json:"games"`
+}
+
+// TrendingGame is the score of a game along with the number of comments and
+// likes made within the window. Each comment scores one plus its likes,
+// decayed by its age.
+type TrendingGame struct {
+	Title    string  `json:"title"`
+	Score    float64 `json:"score"`
+	Comments int     `json:"comments"`
+	Likes    int     `json:"likes"`
+}
+
+// ParseWindow parses a duration for TrendingOptions. As well as the units
+// accepted by time.ParseDuration, whole days and weeks can be given such as
+// "7d" or "2w". The duration must be positive.
+func ParseWindow(s string) (time.Duration, error) {
+	var (
+		d   time.Duration
+		err error
+	)
+
+	switch {
+	case strings.HasSuffix(s, "d"), strings.HasSuffix(s, "w"):
+		unit := 24 * time.Hour
+		if strings.HasSuffix(s, "w") {
+			unit *= 7
+		}
+
+		var n int64
+		n, err = strconv.ParseInt(s[:len(s)-1], 10, 64)
+		if n > math.MaxInt64/int64(unit) {
+			// Too long to be a duration, which would wrap around
+			return 0, fmt.Errorf("Invalid duration %q, must be at most %d days", s, math.MaxInt64/int64(24*time.Hour))
+		}
+		d = time.Duration(n) * unit
+	default:
+		d, err = time.ParseDuration(s)
+	}
+
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("Invalid duration %q, expected a positive duration such as 7d, 2w or 12h", s)
+	}
+
+	return d, nil
+}
+
+// window returns the start and end of the window described by opts
+func (opts TrendingOptions) window() (from, to time.Time) {
+	to = opts.Now
+	if to.IsZero() {
+		to = time.Now()
+	}
+	to = to.UTC()
+
+	return to.Add(-opts.Window), to
+}
+
+// decayedScore is the score of a comment with the given likes made age ago
+func decayedScore(likes int, age, halfLife time.Duration) float64 {
+	return float64(1+likes) * math.Pow(0.5, age.Seconds()/halfLife.Seconds())
+}
+
+// trendingAccumulator scores the comments made within a window as games are
+// added to it
+type trendingAccumulator struct {
+	opts     TrendingOptions
+	from, to time.Time
+	games    []TrendingGame
+}
+
+func newTrendingAcc(opts TrendingOptions) *trendingAccumulator {
+	from, to := opts.window()
+	return &trendingAccumulator{
+		opts:  opts,
+		from:  from,
+		to:    to,
+		games: make([]TrendingGame, 0),
+	}
+}
+
+func (acc *trendingAccumulator) processGame(game Game) {
+	stats := TrendingGame{Title: game.Title}
+	for _, comment := range game.Comments {
+		created := time.Time(comment.DateCreated)
+		if created.Before(acc.from) || created.After(acc.to) {
+			continue
+		}
+
+		stats.Comments++
+		stats.Likes += comment.Like
+		stats.Score += decayedScore(comment.Like, acc.to.Sub(created), acc.opts.HalfLife)
+	}
+
+	if stats.Comments > 0 {
+		acc.games = append(acc.games, stats)
+	}
+}
+
+func (acc *trendingAccumulator) trending() Trending {
+	sortByScore(acc.games)
+	if acc.opts.Limit > 0 && len(acc.games) > acc.opts.Limit {
+		acc.games = acc.games[:acc.opts.Limit]
+	}
+
+	return Trending{
+		From:  acc.from,
+		To:    acc.to,
+		Games: acc.games,
+	}
+}
+
+// sortByScore orders games from highest to lowest score, breaking ties by
+// title
+func sortByScore(games []TrendingGame) {
+	sort.Slice(games, func(i, j int) bool {
+		if games[i].Score != games[j].Score {
+			return games[i].Score > games[j].Score
+		}
+		return games[i].Title < games[j].Title
+	})
+}
+
+type trendingResult struct {
+	Title    string  `bson:"title"`
+	Score    float64 `bson:"score"`
+	Comments int     `bson:"comments"`
+	Likes    int     `bson:"likes"`
+}
+
+// trendingPipeline scores the comments made between from and to, using the
+// same decay as decayedScore. Comment dates are stored as unix timestamps.
+func trendingPipeline(from, to time.Time, halfLife time.Duration) []bson.D {
+	recentComments := bson.D{
+		{"$gte", from.Unix()},
+		{"$lte", to.Unix()},
+	}
+
+	// Skip games without a recent comment before unwinding
+	matchGames := bson.D{
+		{"$match", bson.D{{"comments." + commentDateField, recentComments}}},
+	}
+
+	getComments := bson.D{
+		{"$unwind", "$comments"},
+	}
+
+	matchComments := bson.D{
+		{"$match", bson.D{{"comments." + commentDateField, recentComments}}},
+	}
+
+	decay := bson.D{
+		{"$pow", bson.A{
+			0.5,
+			bson.D{{"$divide", bson.A{
+				bson.D{{"$subtract", bson.A{to.Unix(), "$comments." + commentDateField}}},
+				halfLife.Seconds(),
+			}}},
+		}},
+	}
+
+	groupByGame := bson.D{
+		{
+			"$group", bson.D{
+				{"_id", "$_id"},
+				{"title", bson.D{{"$first", "$title"}}},
+				{"comments", bson.D{{"$sum", 1}}},
+				{"likes", bson.D{{"$sum", "$comments.like"}}},
+				{"score", bson.D{{"$sum", bson.D{
+					{"$multiply", bson.A{
+						bson.D{{"$add", bson.A{1, "$comments.like"}}},
+						decay,
+					}},
+				}}}},
+			},
+		},
+	}
+
+	sortByScore := bson.D{
+		{"$sort", bson.D{{"score", -1}, {"title", 1}}},
+	}
+
+	return []bson.D{
+		matchGames,
+		getComments,
+		matchComments,
+		groupByGame,
+		sortByScore,
+	}
+}
@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "7d", want: 7 * 24 * time.Hour},
+		{s: "2w", want: 14 * 24 * time.Hour},
+		{s: "36h", want: 36 * time.Hour},
+		{s: "106751d", want: 106751 * 24 * time.Hour},
+		{s: "106752d", wantErr: true},
+		{s: "213504d", wantErr: true},
+		{s: "15251w", wantErr: true},
+		{s: "0d", wantErr: true},
+		{s: "-1h", wantErr: true},
+		{s: "d", wantErr: true},
+		{s: "week", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseWindow(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemoryDataSource_Trending(t *testing.T) {
+	now := mustDate("2020-06-10")
+	daysAgo := func(days int) EpochToReadable {
+		return EpochToReadable(now.AddDate(0, 0, -days))
+	}
+
+	mem := NewMemoryDataSource(map[string]Game{
+		"1": {Title: "Classic", Comments: []Comment{
+			{DateCreated: daysAgo(30), Like: 100},
+			{DateCreated: daysAgo(6), Like: 1},
+		}},
+		"2": {Title: "New release", Comments: []Comment{
+			{DateCreated: daysAgo(0), Like: 1},
+			{DateCreated: daysAgo(1), Like: 3},
+		}},
+		"3": {Title: "Forgotten", Comments: []Comment{
+			{DateCreated: daysAgo(8), Like: 50},
+		}},
+	})
+
+	trending, err := mem.Trending(context.Background(), TrendingOptions{
+		Now:      now,
+		Window:   7 * 24 * time.Hour,
+		HalfLife: 24 * time.Hour,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, now.AddDate(0, 0, -7), trending.From)
+	assert.Equal(t, now, trending.To)
+	assert.Equal(t, []TrendingGame{
+		{Title: "New release", Score: 2 + 4*0.5, Comments: 2, Likes: 4},
+		{Title: "Classic", Score: 2 * 1.0 / 64, Comments: 1, Likes: 1},
+	}, trending.Games)
+
+	trending, err = mem.Trending(context.Background(), TrendingOptions{
+		Now:      now,
+		Window:   7 * 24 * time.Hour,
+		HalfLife: 24 * time.Hour,
+		Limit:    1,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, trending.Games, 1, "Only the top game should be listed")
+}
@@ -0,0 +1,663 @@
+// Package config loads the server's configuration. Settings are read from
+// defaults, then a YAML file, then environment variables and then command line
+// flags, each overriding the last.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/service"
+	"github.com/DHBosworth/technichalexercise/service/auth"
+	"github.com/DHBosworth/technichalexercise/service/cors"
+	"github.com/DHBosworth/technichalexercise/service/gameservice"
+	"github.com/DHBosworth/technichalexercise/service/ratelimit"
+	"github.com/DHBosworth/technichalexercise/service/rbac"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix is the prefix of the environment variables read by Load. Each
+// setting's variable is its flag name in upper case with dashes replaced by
+// underscores, such as GAMES_MONGO_URI for -mongo-uri.
+const EnvPrefix = "GAMES_"
+
+// The log formats supported by LogConfig.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Config is the configuration of the server.
+type Config struct {
+	Server     ServerConfig        `yaml:"server"`
+	Log        LogConfig           `yaml:"log"`
+	Mongo      backend.MongoConfig `yaml:"mongo"`
+	Reports    ReportsConfig       `yaml:"reports"`
+	RateLimits RateLimitConfig     `yaml:"rate_limits"`
+	Auth       AuthConfig          `yaml:"auth"`
+	CORS       CORSConfig          `yaml:"cors"`
+	Cache      CacheConfig         `yaml:"cache_control"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	Address string `yaml:"address"`
+	// ReadTimeout, WriteTimeout and IdleTimeout are passed to http.Server,
+	// where 0 means no limit. Writes aren't limited by default so that
+	// streamed reports aren't cut off.
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// RequestTimeout limits how long a request waits for the data source
+	RequestTimeout time.Duration `yaml:"request_timeout"`
+	// ShutdownGrace is how long in-flight requests have to finish when the
+	// server is stopped
+	ShutdownGrace time.Duration `yaml:"shutdown_grace"`
+	TLS           TLSConfig     `yaml:"tls"`
+}
+
+// TLSConfig configures HTTPS. The server is served over plain HTTP unless a
+// certificate and key are set.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+	// ClientCAFile is a bundle of CAs client certificates must be signed by.
+	// Client certificates aren't required if it isn't set.
+	ClientCAFile string `yaml:"client_ca_file"`
+	// ReloadInterval is how often the certificate and key are checked for
+	// changes
+	ReloadInterval time.Duration `yaml:"reload_interval"`
+}
+
+// Enabled reports whether the server should be served over HTTPS
+func (config TLSConfig) Enabled() bool {
+	return config.CertFile != "" || config.KeyFile != ""
+}
+
+// Validate checks the certificate and key are set together
+func (config TLSConfig) Validate() error {
+	if !config.Enabled() {
+		if config.ClientCAFile != "" {
+			return errors.New("TLS client_ca_file requires cert_file and key_file")
+		}
+		return nil
+	}
+
+	if config.CertFile == "" || config.KeyFile == "" {
+		return errors.New("TLS cert_file and key_file must be set together")
+	}
+
+	if config.ReloadInterval <= 0 {
+		return errors.New("TLS reload_interval must be greater than zero")
+	}
+
+	return nil
+}
+
+// LogConfig configures logging.
+type LogConfig struct {
+	// Level is a logrus level, such as debug or info
+	Level string `yaml:"level"`
+	// Format is LogFormatText or LogFormatJSON
+	Format string `yaml:"format"`
+}
+
+// ReportsConfig configures report snapshots and jobs.
+type ReportsConfig struct {
+	// SnapshotInterval is how often a snapshot is saved, 0 disables them
+	SnapshotInterval time.Duration `yaml:"snapshot_interval"`
+	// Dir is a directory to save snapshots in instead of the data source
+	Dir string `yaml:"dir"`
+	// MaxJobs is how many report jobs can run at once and JobTimeout how
+	// long each can run for
+	MaxJobs    int           `yaml:"max_jobs"`
+	JobTimeout time.Duration `yaml:"job_timeout"`
+}
+
+// RateLimitConfig limits how often each client can call groups of
+// endpoints. Limits are written as requests/duration, see
+// ratelimit.ParseLimit, and are disabled if empty.
+type RateLimitConfig struct {
+	// Report limits the report endpoints, which are the most expensive,
+	// besides polling a report job
+	Report string `yaml:"report"`
+	// Game limits the endpoints for a single game
+	Game string `yaml:"game"`
+}
+
+// Validate checks the limits can be parsed
+func (config RateLimitConfig) Validate() error {
+	for _, limit := range []string{config.Report, config.Game} {
+		if limit == "" {
+			continue
+		}
+
+		if _, err := ratelimit.ParseLimit(limit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AuthConfig configures how clients authenticate and the roles they have.
+// Authentication is disabled, leaving every endpoint open, unless API keys or
+// a JWT key are set.
+type AuthConfig struct {
+	// APIKeys maps the name of each client to its API key
+	APIKeys map[string]string `yaml:"api_keys,omitempty"`
+	JWT     JWTConfig         `yaml:"jwt"`
+	// Roles maps the name of a client, or the subject of a token, to its
+	// role. Tokens may also list roles in a roles claim.
+	Roles map[string]string `yaml:"roles,omitempty"`
+	// AnonymousRole is the role of clients who don't authenticate. Empty
+	// requires clients to authenticate for everything but health checks.
+	AnonymousRole string `yaml:"anonymous_role"`
+}
+
+// JWTConfig configures the keys JWT bearer tokens are verified with.
+type JWTConfig struct {
+	// HMACSecret verifies HS256 tokens
+	HMACSecret string `yaml:"hmac_secret"`
+	// RSAPublicKeyFile is a PEM encoded public key or certificate which
+	// verifies RS256 tokens
+	RSAPublicKeyFile string `yaml:"rsa_public_key_file"`
+	// Issuer and Audience are checked against a token's claims when set
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+}
+
+// Enabled reports whether clients can authenticate
+func (config AuthConfig) Enabled() bool {
+	return len(config.APIKeys) > 0 || config.JWT.Enabled()
+}
+
+// Enabled reports whether bearer tokens are accepted
+func (config JWTConfig) Enabled() bool {
+	return config.HMACSecret != "" || config.RSAPublicKeyFile != ""
+}
+
+// Validate checks every API key is set and every role is known
+func (config AuthConfig) Validate() error {
+	for name, key := range config.APIKeys {
+		if name == "" || key == "" {
+			return fmt.Errorf("Auth api_keys must have a name and key, got %q", name)
+		}
+	}
+
+	for name, role := range config.Roles {
+		if _, err := rbac.ParseRole(role); err != nil {
+			return fmt.Errorf("Auth role for %q: %w", name, err)
+		}
+	}
+
+	if config.AnonymousRole != "" {
+		if _, err := rbac.ParseRole(config.AnonymousRole); err != nil {
+			return fmt.Errorf("Auth anonymous_role: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CORSConfig configures which browser clients on other origins can call the
+// service. CORS is disabled unless some origins are allowed.
+type CORSConfig struct {
+	// AllowedOrigins are origins such as https://example.com, or * for any
+	AllowedOrigins []string `yaml:"allowed_origins,omitempty"`
+	// AllowedMethods and AllowedHeaders are what clients can send in
+	// cross-origin requests
+	AllowedMethods []string `yaml:"allowed_methods"`
+	AllowedHeaders []string `yaml:"allowed_headers"`
+	// ExposedHeaders are the response headers clients can read
+	ExposedHeaders []string `yaml:"exposed_headers"`
+	// AllowCredentials allows requests with cookies or an Authorization
+	// header, which can't be used with any origin
+	AllowCredentials bool `yaml:"allow_credentials"`
+	// MaxAge is how long clients cache the response to a preflight request
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// Enabled reports whether any cross-origin requests are allowed
+func (config CORSConfig) Enabled() bool {
+	return len(config.AllowedOrigins) > 0
+}
+
+// Validate checks every origin is a scheme and host, and that credentials
+// aren't allowed from any origin
+func (config CORSConfig) Validate() error {
+	for _, origin := range config.AllowedOrigins {
+		if origin == cors.AnyOrigin {
+			if config.AllowCredentials {
+				return errors.New("CORS allow_credentials can't be used when any origin is allowed")
+			}
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("Invalid CORS origin %q, should be a scheme and host such as https://example.com", origin)
+		}
+	}
+
+	if config.MaxAge < 0 {
+		return errors.New("CORS max_age can't be negative")
+	}
+
+	return nil
+}
+
+// CacheConfig is the Cache-Control header sent with games and reports.
+// Empty sends no header.
+type CacheConfig struct {
+	Game   string `yaml:"game"`
+	Report string `yaml:"report"`
+}
+
+// redacted is shown in place of secrets when the configuration is written
+const redacted = "REDACTED"
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Address:        ":8080",
+			ReadTimeout:    30 * time.Second,
+			IdleTimeout:    2 * time.Minute,
+			RequestTimeout: gameservice.DefaultRequestTimeout,
+			ShutdownGrace:  30 * time.Second,
+			TLS: TLSConfig{
+				ReloadInterval: 10 * time.Second,
+			},
+		},
+		Log: LogConfig{
+			Level:  log.InfoLevel.String(),
+			Format: LogFormatText,
+		},
+		Mongo: backend.DefaultMongoConfig(),
+		Reports: ReportsConfig{
+			SnapshotInterval: time.Hour,
+			MaxJobs:          gameservice.DefaultMaxReportJobs,
+			JobTimeout:       gameservice.DefaultReportJobTimeout,
+		},
+		RateLimits: RateLimitConfig{
+			Report: "30/1m",
+			Game:   "20/1s",
+		},
+		Auth: AuthConfig{
+			AnonymousRole: rbac.Reader.String(),
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+			AllowedHeaders: []string{"Authorization", "Content-Type", auth.APIKeyHeader, service.RequestIDHeader},
+			ExposedHeaders: []string{
+				service.RequestIDHeader,
+				ratelimit.LimitHeader,
+				ratelimit.RemainingHeader,
+				ratelimit.ResetHeader,
+				"Retry-After",
+				"ETag",
+			},
+			MaxAge: 10 * time.Minute,
+		},
+		Cache: CacheConfig{
+			Game:   gameservice.DefaultGameCacheControl,
+			Report: gameservice.DefaultReportCacheControl,
+		},
+	}
+}
+
+// Validate checks the configuration can be used to start the server
+func (config Config) Validate() error {
+	if config.Server.Address == "" {
+		return errors.New("Server address must be set")
+	}
+
+	limits := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"Server read_timeout", config.Server.ReadTimeout},
+		{"Server write_timeout", config.Server.WriteTimeout},
+		{"Server idle_timeout", config.Server.IdleTimeout},
+	}
+	for _, limit := range limits {
+		if limit.value < 0 {
+			return fmt.Errorf("%s must not be negative", limit.name)
+		}
+	}
+
+	if config.Server.RequestTimeout <= 0 || config.Server.ShutdownGrace <= 0 {
+		return errors.New("Server request_timeout and shutdown_grace must be greater than zero")
+	}
+
+	if err := config.Server.TLS.Validate(); err != nil {
+		return err
+	}
+
+	if _, err := log.ParseLevel(config.Log.Level); err != nil {
+		return err
+	}
+
+	if config.Log.Format != LogFormatText && config.Log.Format != LogFormatJSON {
+		return fmt.Errorf("Log format must be %s or %s, got %q", LogFormatText, LogFormatJSON, config.Log.Format)
+	}
+
+	if config.Reports.MaxJobs <= 0 || config.Reports.JobTimeout <= 0 {
+		return errors.New("Reports max_jobs and job_timeout must be greater than zero")
+	}
+
+	if config.Reports.SnapshotInterval < 0 {
+		return errors.New("Reports snapshot_interval must not be negative")
+	}
+
+	if err := config.RateLimits.Validate(); err != nil {
+		return err
+	}
+
+	if err := config.Auth.Validate(); err != nil {
+		return err
+	}
+
+	if err := config.CORS.Validate(); err != nil {
+		return err
+	}
+
+	return config.Mongo.Validate()
+}
+
+// Write writes the configuration as YAML, in the format Load reads. Secrets
+// are replaced with REDACTED.
+func (config Config) Write(w io.Writer) error {
+	if len(config.Auth.APIKeys) > 0 {
+		keys := make(map[string]string, len(config.Auth.APIKeys))
+		for name := range config.Auth.APIKeys {
+			keys[name] = redacted
+		}
+		config.Auth.APIKeys = keys
+	}
+	if config.Auth.JWT.HMACSecret != "" {
+		config.Auth.JWT.HMACSecret = redacted
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+	return err
+}
+
+// Options are the command line options which aren't configuration.
+type Options struct {
+	// File is the YAML file the configuration was read from, if any
+	File string
+	// PrintConfig is set if the configuration should be printed instead of
+	// starting the server
+	PrintConfig bool
+}
+
+// setting is a configuration value which can be set by an environment
+// variable and a flag
+type setting struct {
+	flag  string
+	usage string
+	// field returns a pointer to the string, bool, int, time.Duration, slice
+	// or map being set
+	field func(config *Config) interface{}
+}
+
+var settings = []setting{
+	{"address", "Address the server will listen on", func(c *Config) interface{} { return &c.Server.Address }},
+	{"read-timeout", "How long the server waits to read a request, 0 for no limit", func(c *Config) interface{} { return &c.Server.ReadTimeout }},
+	{"write-timeout", "How long the server waits to write a response, 0 for no limit", func(c *Config) interface{} { return &c.Server.WriteTimeout }},
+	{"idle-timeout", "How long idle keep-alive connections are kept open, 0 for no limit", func(c *Config) interface{} { return &c.Server.IdleTimeout }},
+	{"request-timeout", "How long a request waits for the data source", func(c *Config) interface{} { return &c.Server.RequestTimeout }},
+	{"shutdown-grace", "How long in-flight requests have to finish when the server is stopped", func(c *Config) interface{} { return &c.Server.ShutdownGrace }},
+	{"tls-cert", "PEM encoded certificate to serve HTTPS with, reloaded when it changes", func(c *Config) interface{} { return &c.Server.TLS.CertFile }},
+	{"tls-key", "PEM encoded key for -tls-cert", func(c *Config) interface{} { return &c.Server.TLS.KeyFile }},
+	{"tls-client-ca", "PEM encoded CAs client certificates must be signed by, enables mTLS", func(c *Config) interface{} { return &c.Server.TLS.ClientCAFile }},
+	{"tls-reload-interval", "How often the certificate and key are checked for changes", func(c *Config) interface{} { return &c.Server.TLS.ReloadInterval }},
+	{"rate-limit-report", "Requests each client can make to the report endpoints, as requests/duration, empty for no limit", func(c *Config) interface{} { return &c.RateLimits.Report }},
+	{"rate-limit-game", "Requests each client can make to the endpoints for a game, as requests/duration, empty for no limit", func(c *Config) interface{} { return &c.RateLimits.Game }},
+	{"auth-api-keys", "API keys clients can authenticate with, as name=key pairs separated by commas", func(c *Config) interface{} { return &c.Auth.APIKeys }},
+	{"auth-jwt-hmac-secret", "Secret HS256 bearer tokens are verified with", func(c *Config) interface{} { return &c.Auth.JWT.HMACSecret }},
+	{"auth-jwt-rsa-public-key", "PEM encoded public key or certificate RS256 bearer tokens are verified with", func(c *Config) interface{} { return &c.Auth.JWT.RSAPublicKeyFile }},
+	{"auth-jwt-issuer", "Issuer bearer tokens must have", func(c *Config) interface{} { return &c.Auth.JWT.Issuer }},
+	{"auth-jwt-audience", "Audience bearer tokens must have", func(c *Config) interface{} { return &c.Auth.JWT.Audience }},
+	{"auth-roles", "Roles of clients and token subjects, as name=role pairs separated by commas", func(c *Config) interface{} { return &c.Auth.Roles }},
+	{"auth-anonymous-role", "Role of clients who don't authenticate, empty to require authentication", func(c *Config) interface{} { return &c.Auth.AnonymousRole }},
+	{"cors-allowed-origins", "Origins browser clients can call the service from, separated by commas, * for any", func(c *Config) interface{} { return &c.CORS.AllowedOrigins }},
+	{"cors-allowed-methods", "Methods cross-origin requests can use, separated by commas", func(c *Config) interface{} { return &c.CORS.AllowedMethods }},
+	{"cors-allowed-headers", "Headers cross-origin requests can send, separated by commas", func(c *Config) interface{} { return &c.CORS.AllowedHeaders }},
+	{"cors-exposed-headers", "Response headers cross-origin clients can read, separated by commas", func(c *Config) interface{} { return &c.CORS.ExposedHeaders }},
+	{"cors-allow-credentials", "Allow cross-origin requests with credentials", func(c *Config) interface{} { return &c.CORS.AllowCredentials }},
+	{"cors-max-age", "How long clients cache the response to a preflight request", func(c *Config) interface{} { return &c.CORS.MaxAge }},
+	{"cache-control-game", "Cache-Control header sent with games, empty for none", func(c *Config) interface{} { return &c.Cache.Game }},
+	{"cache-control-report", "Cache-Control header sent with reports, empty for none", func(c *Config) interface{} { return &c.Cache.Report }},
+	{"log-level", "Logging level, such as debug or info", func(c *Config) interface{} { return &c.Log.Level }},
+	{"log-format", "Logging format, text or json", func(c *Config) interface{} { return &c.Log.Format }},
+	{"mongo-uri", "URI of the mongoDB instance", func(c *Config) interface{} { return &c.Mongo.URI }},
+	{"mongo-database", "mongoDB database holding the games", func(c *Config) interface{} { return &c.Mongo.Database }},
+	{"mongo-games-collection", "mongoDB collection holding the games", func(c *Config) interface{} { return &c.Mongo.GamesCollection }},
+	{"mongo-reports-collection", "mongoDB collection report snapshots are saved in", func(c *Config) interface{} { return &c.Mongo.ReportsCollection }},
+	{"mongo-connect-timeout", "How long to wait when connecting to mongoDB", func(c *Config) interface{} { return &c.Mongo.ConnectTimeout }},
+	{"mongo-disconnect-timeout", "How long to wait when disconnecting from mongoDB", func(c *Config) interface{} { return &c.Mongo.DisconnectTimeout }},
+	{"snapshot-interval", "How often report snapshots are saved, 0 disables snapshots", func(c *Config) interface{} { return &c.Reports.SnapshotInterval }},
+	{"report-dir", "Directory to save report snapshots in instead of the data source", func(c *Config) interface{} { return &c.Reports.Dir }},
+	{"report-max-jobs", "How many report jobs can run at once", func(c *Config) interface{} { return &c.Reports.MaxJobs }},
+	{"report-job-timeout", "How long a report job can run for", func(c *Config) interface{} { return &c.Reports.JobTimeout }},
+}
+
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(s.flag, "-", "_", -1))
+}
+
+// set parses value into the setting's field in config
+func (s setting) set(config *Config, value string) error {
+	switch field := s.field(config).(type) {
+	case *string:
+		*field = value
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field = d
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field = b
+	case *int:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field = i
+	case *[]string:
+		*field = parseList(value)
+	case *map[string]string:
+		pairs, err := parsePairs(value)
+		if err != nil {
+			return err
+		}
+		*field = pairs
+	default:
+		panic("config: unsupported type for " + s.flag)
+	}
+
+	return nil
+}
+
+// format returns the setting's value in config as it would be set
+func (s setting) format(config *Config) string {
+	switch field := s.field(config).(type) {
+	case *string:
+		return *field
+	case *bool:
+		return strconv.FormatBool(*field)
+	case *int:
+		return strconv.Itoa(*field)
+	case *time.Duration:
+		return field.String()
+	case *[]string:
+		return strings.Join(*field, ",")
+	case *map[string]string:
+		// Maps only hold secrets, which shouldn't be shown in the usage
+		return ""
+	}
+
+	return ""
+}
+
+// parseList parses values separated by commas. An empty string is an empty
+// list.
+func parseList(s string) []string {
+	var list []string
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+
+	return list
+}
+
+// parsePairs parses name=value pairs separated by commas
+func parsePairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	if s == "" {
+		return pairs, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("Should be name=value pairs separated by commas")
+		}
+
+		pairs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return pairs, nil
+}
+
+// flagValue records the value of a flag so that it can be applied once the
+// file and environment have been read
+type flagValue struct {
+	value  string
+	isBool bool
+}
+
+// IsBoolFlag lets boolean settings be set without a value, such as
+// -cors-allow-credentials
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+func (v *flagValue) String() string {
+	return v.value
+}
+
+func (v *flagValue) Set(value string) error {
+	v.value = value
+	return nil
+}
+
+// Load reads the configuration from the command line arguments, excluding the
+// program name, and the environment, which is read with lookupEnv. The YAML
+// file is named by -config or GAMES_CONFIG. The result is validated.
+func Load(args []string, lookupEnv func(string) (string, bool)) (Config, Options, error) {
+	config := Default()
+	var opts Options
+
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&opts.File, "config", "", "YAML configuration file, also read from "+EnvPrefix+"CONFIG")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "Print the configuration and exit")
+	port := fs.String("p", "", "Port the server will listen on, overrides -address")
+	verbose := fs.Bool("v", false, "Set the logging level to debug, overrides -log-level")
+
+	values := make(map[string]*flagValue, len(settings))
+	for _, s := range settings {
+		_, isBool := s.field(&config).(*bool)
+		values[s.flag] = &flagValue{value: s.format(&config), isBool: isBool}
+		fs.Var(values[s.flag], s.flag, s.usage+", also read from "+s.env())
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return config, opts, err
+	}
+
+	if opts.File == "" {
+		opts.File, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+	if opts.File != "" {
+		if err := readFile(opts.File, &config); err != nil {
+			return config, opts, err
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := lookupEnv(s.env())
+		if !ok {
+			continue
+		}
+
+		if err := s.set(&config, value); err != nil {
+			return config, opts, fmt.Errorf("Invalid %s: %v", s.env(), err)
+		}
+	}
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if err == nil && s.flag == f.Name {
+				if setErr := s.set(&config, values[s.flag].value); setErr != nil {
+					err = fmt.Errorf("Invalid -%s: %v", s.flag, setErr)
+				}
+			}
+		}
+
+		switch f.Name {
+		case "p":
+			config.Server.Address = ":" + *port
+		case "v":
+			if *verbose {
+				config.Log.Level = log.DebugLevel.String()
+			}
+		}
+	})
+	if err != nil {
+		return config, opts, err
+	}
+
+	return config, opts, config.Validate()
+}
+
+// readFile overrides config with the settings in the YAML file. Settings
+// missing from the file are left as they are.
+func readFile(name string, config *Config) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return fmt.Errorf("Unable to read config file %s: %v", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+}
+
+func writeFile(t *testing.T, contents string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	name := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(name, []byte(contents), 0644); err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+
+	return name
+}
+
+func TestLoad(t *testing.T) {
+	file := writeFile(t, `
+server:
+  address: ":9000"
+  request_timeout: 20s
+log:
+  level: warning
+mongo:
+  database: fromFile
+  games_collection: fileGames
+`)
+
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		check   func(t *testing.T, config Config, opts Options)
+		wantErr bool
+	}{
+		{
+			name: "Defaults",
+			check: func(t *testing.T, config Config, opts Options) {
+				assert.Equal(t, Default(), config)
+				assert.Equal(t, Options{}, opts)
+			},
+		},
+		{
+			name: "File overrides defaults",
+			args: []string{"-config", file},
+			check: func(t *testing.T, config Config, opts Options) {
+				assert.Equal(t, ":9000", config.Server.Address)
+				assert.Equal(t, 20*time.Second, config.Server.RequestTimeout)
+				assert.Equal(t, "warning", config.Log.Level)
+				assert.Equal(t, "fromFile", config.Mongo.Database)
+				assert.Equal(t, Default().Mongo.URI, config.Mongo.URI, "Settings missing from the file should keep their defaults")
+				assert.Equal(t, file, opts.File)
+			},
+		},
+		{
+			name: "Environment overrides file",
+			env: map[string]string{
+				"GAMES_CONFIG":                 file,
+				"GAMES_MONGO_DATABASE":         "fromEnv",
+				"GAMES_MONGO_CONNECT_TIMEOUT":  "3s",
+				"GAMES_MONGO_GAMES_COLLECTION": "envGames",
+			},
+			args: []string{"-mongo-games-collection", "flagGames"},
+			check: func(t *testing.T, config Config, opts Options) {
+				assert.Equal(t, "fromEnv", config.Mongo.Database)
+				assert.Equal(t, 3*time.Second, config.Mongo.ConnectTimeout)
+				assert.Equal(t, "flagGames", config.Mongo.GamesCollection, "Flags should override the environment")
+				assert.Equal(t, ":9000", config.Server.Address)
+			},
+		},
+		{
+			name: "Shorthand flags",
+			args: []string{"-p", "8081", "-v", "-print-config"},
+			env:  map[string]string{"GAMES_LOG_LEVEL": "error"},
+			check: func(t *testing.T, config Config, opts Options) {
+				assert.Equal(t, ":8081", config.Server.Address)
+				assert.Equal(t, "debug", config.Log.Level)
+				assert.True(t, opts.PrintConfig)
+			},
+		},
+		{
+			name:    "Invalid duration",
+			env:     map[string]string{"GAMES_SHUTDOWN_GRACE": "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid log format",
+			args:    []string{"-log-format", "xml"},
+			wantErr: true,
+		},
+		{
+			name:    "Negative snapshot interval",
+			args:    []string{"-snapshot-interval", "-1h"},
+			wantErr: true,
+		},
+		{
+			name: "Report jobs",
+			env:  map[string]string{"GAMES_REPORT_MAX_JOBS": "2"},
+			args: []string{"-report-job-timeout", "90s"},
+			check: func(t *testing.T, config Config, opts Options) {
+				assert.Equal(t, 2, config.Reports.MaxJobs)
+				assert.Equal(t, 90*time.Second, config.Reports.JobTimeout)
+			},
+		},
+		{
+			name:    "Invalid max jobs",
+			args:    []string{"-report-max-jobs", "0"},
+			wantErr: true,
+		},
+		{
+			name:    "Missing mongo database",
+			args:    []string{"-mongo-database", ""},
+			wantErr: true,
+		},
+		{
+			name: "TLS",
+			env:  map[string]string{"GAMES_TLS_CERT": "cert.pem", "GAMES_TLS_KEY": "key.pem"},
+			args: []string{"-tls-client-ca", "ca.pem"},
+			check: func(t *testing.T, config Config, opts Options) {
+				assert.True(t, config.Server.TLS.Enabled())
+				assert.Equal(t, "ca.pem", config.Server.TLS.ClientCAFile)
+			},
+		},
+		{
+			name:    "TLS certificate without key",
+			args:    []string{"-tls-cert", "cert.pem"},
+			wantErr: true,
+		},
+		{
+			name:    "Client CA without TLS",
+			args:    []string{"-tls-client-ca", "ca.pem"},
+			wantErr: true,
+		},
+		{
+			name: "Auth",
+			env: map[string]string{
+				"GAMES_AUTH_API_KEYS":       "reporting=key-1,admin=key-2",
+				"GAMES_AUTH_ANONYMOUS_ROLE": "",
+			},
+			args: []string{"-auth-roles", "reporting=editor,admin=admin"},
+			check: func(t *testing.T, config Config, opts Options) {
+				assert.True(t, config.Auth.Enabled())
+				assert.Equal(t, map[string]string{"reporting": "key-1", "admin": "key-2"}, config.Auth.APIKeys)
+				assert.Equal(t, map[string]string{"reporting": "editor", "admin": "admin"}, config.Auth.Roles)
+				assert.Equal(t, "", config.Auth.AnonymousRole)
+			},
+		},
+		{
+			name:    "Unknown role",
+			args:    []string{"-auth-roles", "reporting=owner"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown anonymous role",
+			args:    []string{"-auth-anonymous-role", "guest"},
+			wantErr: true,
+		},
+		{
+			name: "CORS",
+			env:  map[string]string{"GAMES_CORS_ALLOWED_ORIGINS": "https://app.example.com, http://localhost:3000"},
+			args: []string{"-cors-allow-credentials", "-cors-allowed-methods", "GET"},
+			check: func(t *testing.T, config Config, opts Options) {
+				assert.True(t, config.CORS.Enabled())
+				assert.Equal(t, []string{"https://app.example.com", "http://localhost:3000"}, config.CORS.AllowedOrigins)
+				assert.Equal(t, []string{"GET"}, config.CORS.AllowedMethods)
+				assert.True(t, config.CORS.AllowCredentials)
+			},
+		},
+		{
+			name:    "CORS credentials from any origin",
+			args:    []string{"-cors-allowed-origins", "*", "-cors-allow-credentials=true"},
+			wantErr: true,
+		},
+		{
+			name:    "CORS origin with path",
+			args:    []string{"-cors-allowed-origins", "https://app.example.com/games"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid bool",
+			env:     map[string]string{"GAMES_CORS_ALLOW_CREDENTIALS": "sometimes"},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown file setting",
+			args:    []string{"-config", writeFile(t, "server:\n  port: 80\n")},
+			wantErr: true,
+		},
+		{
+			name:    "Missing file",
+			args:    []string{"-config", filepath.Join(os.TempDir(), "does-not-exist.yaml")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, opts, err := Load(tt.args, env(tt.env))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				tt.check(t, config, opts)
+			}
+		})
+	}
+}
+
+func TestConfig_Write(t *testing.T) {
+	want := Default()
+	want.Reports.Dir = "/var/reports"
+	want.Server.WriteTimeout = time.Minute
+	want.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	want.CORS.AllowCredentials = true
+
+	var buf bytes.Buffer
+	if err := want.Write(&buf); err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+	assert.Contains(t, buf.String(), "write_timeout: 1m0s", "Durations should be readable")
+
+	got, _, err := Load([]string{"-config", writeFile(t, buf.String())}, env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, want, got, "Printed config should load back unchanged")
+}
+
+func TestConfig_WriteRedactsSecrets(t *testing.T) {
+	config := Default()
+	config.Auth.APIKeys = map[string]string{"reporting": "key-1"}
+	config.Auth.JWT.HMACSecret = "hmac-value"
+
+	var buf bytes.Buffer
+	if err := config.Write(&buf); err != nil {
+		t.Fatalf("Unable to write config: %v", err)
+	}
+
+	assert.NotContains(t, buf.String(), "key-1")
+	assert.NotContains(t, buf.String(), "hmac-value")
+	assert.Contains(t, buf.String(), "reporting: "+redacted)
+	assert.Equal(t, "key-1", config.Auth.APIKeys["reporting"], "Writing should not change the config")
+}
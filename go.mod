@@ -12,4 +12,5 @@ require (
 	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37 // indirect
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a // indirect
 	golang.org/x/sys v0.0.0-20200515095857-1151b9dac4a9 // indirect
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -0,0 +1,41 @@
+// Package logging carries a request scoped logger through a context, so that
+// everything logged while handling a request can be tied back to it.
+package logging
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *log.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, or the standard logger if
+// there isn't one
+func FromContext(ctx context.Context) *log.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*log.Entry); ok {
+		return logger
+	}
+
+	return log.NewEntry(log.StandardLogger())
+}
+
+// WithRequestID returns a copy of ctx carrying the id of the request it was
+// created for, and a logger which includes the id in every entry
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return WithLogger(ctx, FromContext(ctx).WithField("request_id", id))
+}
+
+// RequestID returns the id set by WithRequestID, or an empty string
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
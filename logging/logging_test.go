@@ -0,0 +1,18 @@
+package logging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithRequestID(t *testing.T) {
+	ctx := context.Background()
+	assert.NotNil(t, FromContext(ctx), "The standard logger should be used by default")
+	assert.Equal(t, "", RequestID(ctx))
+
+	ctx = WithRequestID(ctx, "abc-123")
+	assert.Equal(t, "abc-123", RequestID(ctx))
+	assert.Equal(t, "abc-123", FromContext(ctx).Data["request_id"], "The logger should include the request id")
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+)
+
+// dataSource records the latency and errors of each operation on a data
+// source, and of the aggregation pipelines it runs, see
+// backend.WithPipelineObserver.
+type dataSource struct {
+	ds        backend.ServiceDataSource
+	latency   *Histogram
+	errors    *Counter
+	pipelines backend.PipelineObserver
+}
+
+// storedDataSource is a dataSource whose data source is also a
+// backend.ReportStore
+type storedDataSource struct {
+	dataSource
+	store backend.ReportStore
+}
+
+// InstrumentDataSource wraps ds so that its operations are recorded in reg.
+// The result is a backend.ReportStore only if ds is, so the service still
+// knows whether report history is available.
+func InstrumentDataSource(ds backend.ServiceDataSource, reg *Registry) backend.ServiceDataSource {
+	pipelineLatency := reg.NewHistogram("datasource_pipeline_duration_seconds",
+		"Time taken by data source aggregation pipelines in seconds.", DefaultBuckets, "pipeline")
+	pipelineErrors := reg.NewCounter("datasource_pipeline_errors_total",
+		"Number of data source aggregation pipelines which failed.", "pipeline")
+
+	instrumented := dataSource{
+		ds: ds,
+		latency: reg.NewHistogram("datasource_operation_duration_seconds",
+			"Time taken by data source operations in seconds.", DefaultBuckets, "operation"),
+		errors: reg.NewCounter("datasource_operation_errors_total",
+			"Number of data source operations which failed.", "operation"),
+		pipelines: func(pipeline string, duration time.Duration, err error) {
+			pipelineLatency.Observe(duration.Seconds(), pipeline)
+			if err != nil {
+				pipelineErrors.Inc(pipeline)
+			}
+		},
+	}
+
+	if store, ok := ds.(backend.ReportStore); ok {
+		return storedDataSource{dataSource: instrumented, store: store}
+	}
+
+	return instrumented
+}
+
+// observe starts timing operation, returning the context to perform it with
+// and a function to call with its error once it is done. Cancelled
+// operations aren't counted as errors as they are stopped by the caller.
+func (ds dataSource) observe(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+
+	return backend.WithPipelineObserver(ctx, ds.pipelines), func(err error) {
+		ds.latency.Observe(time.Since(start).Seconds(), operation)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			ds.errors.Inc(operation)
+		}
+	}
+}
+
+func (ds dataSource) Game(ctx context.Context, id string) (backend.Game, error) {
+	ctx, done := ds.observe(ctx, "Game")
+	game, err := ds.ds.Game(ctx, id)
+	done(err)
+	return game, err
+}
+
+func (ds dataSource) Report(ctx context.Context) (backend.Report, error) {
+	ctx, done := ds.observe(ctx, "Report")
+	report, err := ds.ds.Report(ctx)
+	done(err)
+	return report, err
+}
+
+func (ds dataSource) StreamReport(ctx context.Context, row func(backend.GameAverageLikes) error) (backend.Report, error) {
+	ctx, done := ds.observe(ctx, "StreamReport")
+	report, err := ds.ds.StreamReport(ctx, row)
+	done(err)
+	return report, err
+}
+
+func (ds dataSource) ReportSections(ctx context.Context, names []string) (map[string]interface{}, error) {
+	ctx, done := ds.observe(ctx, "ReportSections")
+	sections, err := ds.ds.ReportSections(ctx, names)
+	done(err)
+	return sections, err
+}
+
+func (ds dataSource) TimeSeries(ctx context.Context, interval backend.Interval) (backend.TimeSeries, error) {
+	ctx, done := ds.observe(ctx, "TimeSeries")
+	series, err := ds.ds.TimeSeries(ctx, interval)
+	done(err)
+	return series, err
+}
+
+func (ds dataSource) Trending(ctx context.Context, opts backend.TrendingOptions) (backend.Trending, error) {
+	ctx, done := ds.observe(ctx, "Trending")
+	trending, err := ds.ds.Trending(ctx, opts)
+	done(err)
+	return trending, err
+}
+
+func (ds dataSource) GameStats(ctx context.Context, id string, top int) (backend.GameStats, error) {
+	ctx, done := ds.observe(ctx, "GameStats")
+	stats, err := ds.ds.GameStats(ctx, id, top)
+	done(err)
+	return stats, err
+}
+
+func (ds dataSource) SimilarGames(ctx context.Context, id string, limit int) ([]backend.SimilarGame, error) {
+	ctx, done := ds.observe(ctx, "SimilarGames")
+	similar, err := ds.ds.SimilarGames(ctx, id, limit)
+	done(err)
+	return similar, err
+}
+
+func (ds storedDataSource) SaveReport(ctx context.Context, snapshot backend.ReportSnapshot) error {
+	ctx, done := ds.observe(ctx, "SaveReport")
+	err := ds.store.SaveReport(ctx, snapshot)
+	done(err)
+	return err
+}
+
+func (ds storedDataSource) ReportHistory(ctx context.Context, from, to time.Time) ([]backend.ReportSnapshot, error) {
+	ctx, done := ds.observe(ctx, "ReportHistory")
+	history, err := ds.store.ReportHistory(ctx, from, to)
+	done(err)
+	return history, err
+}
+
+func (ds storedDataSource) ReportAt(ctx context.Context, t time.Time) (backend.ReportSnapshot, error) {
+	ctx, done := ds.observe(ctx, "ReportAt")
+	snapshot, err := ds.store.ReportAt(ctx, t)
+	done(err)
+	return snapshot, err
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware records the number, latency and concurrency of the requests
+// served by the handler it wraps. route names the route a request matched,
+// such as its template, so that paths with ids are grouped together.
+func Middleware(reg *Registry, route func(*http.Request) string) func(http.Handler) http.Handler {
+	requests := reg.NewCounter("http_requests_total",
+		"Number of HTTP requests served.", "method", "route", "code")
+	latency := reg.NewHistogram("http_request_duration_seconds",
+		"Time taken to serve HTTP requests in seconds.", DefaultBuckets, "method", "route")
+	inFlight := reg.NewGauge("http_requests_in_flight",
+		"Number of HTTP requests currently being served.")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			inFlight.Add(1)
+			defer inFlight.Add(-1)
+
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			name := route(r)
+			requests.Inc(r.Method, name, strconv.Itoa(rec.status))
+			latency.Observe(time.Since(start).Seconds(), r.Method, name)
+		})
+	}
+}
+
+// statusRecorder records the status of a response. It flushes through to the
+// underlying writer so that streamed responses still work.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(b)
+}
+
+// Flush sends any buffered data to the client, if the underlying writer
+// supports it
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func scrape(t *testing.T, reg *Registry) string {
+	resp := httptest.NewRecorder()
+	reg.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, ContentType, resp.Header().Get("Content-Type"))
+	return resp.Body.String()
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounter("requests_total", "Requests\nserved.", "path")
+	gauge := reg.NewGauge("in_flight", "In flight.")
+	histogram := reg.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+
+	counter.Inc(`/a"b\c`)
+	counter.Add(2, "/")
+	gauge.Add(3)
+	gauge.Add(-1)
+	histogram.Observe(0.05, "get")
+	histogram.Observe(0.5, "get")
+	histogram.Observe(5, "get")
+
+	want := `# HELP in_flight In flight.
+# TYPE in_flight gauge
+in_flight 2
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="get",le="0.1"} 1
+latency_seconds_bucket{op="get",le="1"} 2
+latency_seconds_bucket{op="get",le="+Inf"} 3
+latency_seconds_sum{op="get"} 5.55
+latency_seconds_count{op="get"} 3
+# HELP requests_total Requests\nserved.
+# TYPE requests_total counter
+requests_total{path="/"} 2
+requests_total{path="/a\"b\\c"} 1
+`
+	assert.Equal(t, want, scrape(t, reg))
+}
+
+func TestRegistry_panics(t *testing.T) {
+	reg := NewRegistry()
+	counter := reg.NewCounter("total", "Total.", "label")
+
+	assert.Panics(t, func() { reg.NewGauge("total", "Total.") }, "Names should be unique")
+	assert.Panics(t, func() { counter.Inc() }, "Every label should have a value")
+	assert.Panics(t, func() { counter.Add(-1, "value") }, "Counters shouldn't decrease")
+	assert.Panics(t, func() { reg.NewHistogram("h", "H.", []float64{1, 0.5}) }, "Buckets should be sorted")
+}
+
+func TestMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	middleware := Middleware(reg, func(r *http.Request) string {
+		return "/games/{id}"
+	})
+
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, scrape(t, reg), "http_requests_in_flight 1\n")
+		if r.URL.Path == "/games/2" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte("{}"))
+	}))
+
+	for _, path := range []string{"/games/1", "/games/1", "/games/2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	got := scrape(t, reg)
+	assert.Contains(t, got, `http_requests_total{method="GET",route="/games/{id}",code="200"} 2`)
+	assert.Contains(t, got, `http_requests_total{method="GET",route="/games/{id}",code="404"} 1`)
+	assert.Contains(t, got, `http_request_duration_seconds_count{method="GET",route="/games/{id}"} 3`)
+	assert.Contains(t, got, "http_requests_in_flight 0\n")
+}
+
+func TestInstrumentDataSource(t *testing.T) {
+	memory := backend.NewMemoryDataSource(map[string]backend.Game{
+		"1": {Title: "Uncharted 4"},
+	})
+
+	reg := NewRegistry()
+	ds := InstrumentDataSource(memory, reg)
+	_, isStore := ds.(backend.ReportStore)
+	assert.False(t, isStore, "Only data sources which store reports should be report stores")
+
+	ctx := context.Background()
+	_, err := ds.Game(ctx, "1")
+	assert.NoError(t, err)
+	_, err = ds.Game(ctx, "2")
+	assert.Error(t, err)
+	_, err = ds.Report(ctx)
+	assert.NoError(t, err)
+
+	got := scrape(t, reg)
+	assert.Contains(t, got, `datasource_operation_duration_seconds_count{operation="Game"} 2`)
+	assert.Contains(t, got, `datasource_operation_duration_seconds_count{operation="Report"} 1`)
+	assert.Contains(t, got, `datasource_operation_errors_total{operation="Game"} 1`)
+	assert.NotContains(t, got, `datasource_operation_errors_total{operation="Report"}`)
+
+	stored := InstrumentDataSource(backend.WithReportStore(memory, nil), NewRegistry())
+	_, isStore = stored.(backend.ReportStore)
+	assert.True(t, isStore, "Report stores should stay report stores")
+}
+
+func TestInstrumentDataSource_pipelines(t *testing.T) {
+	reg := NewRegistry()
+	ds := InstrumentDataSource(backend.NewMemoryDataSource(nil), reg).(dataSource)
+
+	ds.pipelines("trending", 0, nil)
+	ds.pipelines("trending", 0, errors.New("Failed"))
+
+	got := scrape(t, reg)
+	assert.Contains(t, got, `datasource_pipeline_duration_seconds_count{pipeline="trending"} 2`)
+	assert.Contains(t, got, `datasource_pipeline_errors_total{pipeline="trending"} 1`)
+}
+
+func TestPoolMonitor(t *testing.T) {
+	reg := NewRegistry()
+	monitor := PoolMonitor(reg)
+
+	for _, typ := range []string{
+		event.ConnectionCreated,
+		event.ConnectionCreated,
+		event.GetSucceeded,
+		event.GetSucceeded,
+		event.ConnectionReturned,
+		event.ConnectionClosed,
+		event.GetFailed,
+	} {
+		monitor.Event(&event.PoolEvent{Type: typ})
+	}
+
+	got := scrape(t, reg)
+	for _, line := range []string{
+		"mongo_pool_connections_open 1",
+		"mongo_pool_connections_in_use 1",
+		"mongo_pool_checkout_failures_total 1",
+	} {
+		assert.True(t, strings.Contains(got, line+"\n"), "Expected %q in %s", line, got)
+	}
+}
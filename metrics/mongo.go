@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// PoolMonitor returns a monitor for a mongoDB client's connection pool which
+// records the open and in use connections and failed checkouts in reg. Pass
+// it to the client with options.ClientOptions.SetPoolMonitor.
+func PoolMonitor(reg *Registry) *event.PoolMonitor {
+	open := reg.NewGauge("mongo_pool_connections_open",
+		"Number of connections open in the mongoDB connection pool.")
+	inUse := reg.NewGauge("mongo_pool_connections_in_use",
+		"Number of connections checked out of the mongoDB connection pool.")
+	failed := reg.NewCounter("mongo_pool_checkout_failures_total",
+		"Number of failed attempts to check a connection out of the mongoDB connection pool.")
+
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				open.Add(1)
+			case event.ConnectionClosed:
+				open.Add(-1)
+			case event.GetSucceeded:
+				inUse.Add(1)
+			case event.ConnectionReturned:
+				inUse.Add(-1)
+			case event.GetFailed:
+				failed.Inc()
+			}
+		},
+	}
+}
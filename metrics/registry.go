@@ -0,0 +1,282 @@
+// Package metrics records service metrics and serves them in the Prometheus
+// text exposition format. It provides middleware for the service's handler, a
+// wrapper for data sources and a monitor for mongoDB's connection pool.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the histogram buckets used for latencies, in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds metrics and serves them as an http.Handler.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]*metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]*metric),
+	}
+}
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+// metric is a named family of series, one per combination of label values
+type metric struct {
+	name    string
+	help    string
+	typ     metricType
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// counts holds the number of observations in each bucket for histograms
+	counts []uint64
+	count  uint64
+}
+
+// register adds a metric, panicking if the name is already taken as metrics
+// are registered once at start up
+func (reg *Registry) register(m *metric) *metric {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	if _, exists := reg.metrics[m.name]; exists {
+		panic("metrics: " + m.name + " registered twice")
+	}
+
+	m.series = make(map[string]*series)
+	if len(m.labels) == 0 {
+		// Metrics without labels are reported from the start
+		m.get(nil)
+	}
+
+	reg.metrics[m.name] = m
+	return m
+}
+
+// get returns the series for labelValues, creating it if needed. The registry
+// must be locked.
+func (m *metric) get(labelValues []string) *series {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s has labels %v, got values %v", m.name, m.labels, labelValues))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if m.typ == histogramType {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+
+	return s
+}
+
+// Counter is a metric which only goes up, such as the number of requests.
+type Counter struct {
+	reg *Registry
+	m   *metric
+}
+
+// NewCounter registers a counter with the given label names
+func (reg *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{reg: reg, m: reg.register(&metric{name: name, help: help, typ: counterType, labels: labels})}
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the series with the given label
+// values
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counters can't decrease")
+	}
+
+	c.reg.mu.Lock()
+	c.m.get(labelValues).value += v
+	c.reg.mu.Unlock()
+}
+
+// Gauge is a metric which can go up and down, such as requests in flight.
+type Gauge struct {
+	reg *Registry
+	m   *metric
+}
+
+// NewGauge registers a gauge with the given label names
+func (reg *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{reg: reg, m: reg.register(&metric{name: name, help: help, typ: gaugeType, labels: labels})}
+}
+
+// Set sets the series with the given label values to v
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.reg.mu.Lock()
+	g.m.get(labelValues).value = v
+	g.reg.mu.Unlock()
+}
+
+// Add adds v, which may be negative, to the series with the given label
+// values
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.reg.mu.Lock()
+	g.m.get(labelValues).value += v
+	g.reg.mu.Unlock()
+}
+
+// Histogram counts observations, such as latencies, in buckets.
+type Histogram struct {
+	reg *Registry
+	m   *metric
+}
+
+// NewHistogram registers a histogram with the given upper bounds for its
+// buckets, in increasing order, and label names
+func (reg *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets for " + name + " aren't in increasing order")
+	}
+
+	return &Histogram{reg: reg, m: reg.register(&metric{
+		name:    name,
+		help:    help,
+		typ:     histogramType,
+		labels:  labels,
+		buckets: buckets,
+	})}
+}
+
+// Observe records v in the series with the given label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.reg.mu.Lock()
+	defer h.reg.mu.Unlock()
+
+	s := h.m.get(labelValues)
+	s.value += v
+	s.count++
+	for i, upper := range h.m.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+}
+
+// ServeHTTP writes every metric in the text exposition format
+func (reg *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+
+	buf := bufio.NewWriter(w)
+	reg.write(buf)
+	buf.Flush()
+}
+
+// write writes the metrics in name order, each with its series ordered by
+// label values so the output is stable
+func (reg *Registry) write(w *bufio.Writer) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	names := make([]string, 0, len(reg.metrics))
+	for name := range reg.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := reg.metrics[name]
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.typ)
+
+		keys := make([]string, 0, len(m.series))
+		for key := range m.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := m.series[key]
+			if m.typ != histogramType {
+				fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(m.labels, s.labelValues), formatFloat(s.value))
+				continue
+			}
+
+			labels := append(append([]string(nil), m.labels...), "le")
+			for i, upper := range m.buckets {
+				values := append(append([]string(nil), s.labelValues...), formatFloat(upper))
+				fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(labels, values), s.counts[i])
+			}
+			values := append(append([]string(nil), s.labelValues...), "+Inf")
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, formatLabels(labels, values), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", m.name, formatLabels(m.labels, s.labelValues), formatFloat(s.value))
+			fmt.Fprintf(w, "%s_count%s %d\n", m.name, formatLabels(m.labels, s.labelValues), s.count)
+		}
+	}
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
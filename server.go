@@ -1,35 +1,263 @@
 package main
 
 import (
+	"context"
+	"crypto/rsa"
 	"flag"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/config"
+	"github.com/DHBosworth/technichalexercise/metrics"
 	"github.com/DHBosworth/technichalexercise/service"
+	"github.com/DHBosworth/technichalexercise/service/auth"
+	"github.com/DHBosworth/technichalexercise/service/cors"
+	"github.com/DHBosworth/technichalexercise/service/ratelimit"
+	"github.com/DHBosworth/technichalexercise/service/rbac"
+	"github.com/DHBosworth/technichalexercise/tlsconfig"
 	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func init() {
 	log.SetOutput(os.Stdout)
-	log.SetLevel(log.DebugLevel)
-
-	flag.Parse()
 }
 
-var addr = flag.String("p", "8080", "Port the server will listen on")
+// configureLogging sets the level and format of the standard logger. The
+// config has been validated so the level is known to parse.
+func configureLogging(logConfig config.LogConfig) {
+	level, _ := log.ParseLevel(logConfig.Level)
+	log.SetLevel(level)
+
+	if logConfig.Format == config.LogFormatJSON {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+}
 
 func main() {
-	data, err := backend.NewMongoDataSource("mongodb://localhost:27017")
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if opts.PrintConfig {
+		if err := cfg.Write(os.Stdout); err != nil {
+			log.Fatalf("Unable to print configuration: %v", err)
+		}
+		return
+	}
+
+	configureLogging(cfg.Log)
+	if opts.File != "" {
+		log.Debugf("Read configuration from %s", opts.File)
+	}
+
+	registry := metrics.NewRegistry()
+
+	data, err := backend.NewMongoDataSource(cfg.Mongo,
+		options.Client().SetPoolMonitor(metrics.PoolMonitor(registry)))
 	if err != nil {
 		log.Fatalf("Unable to create mongo db data source: %v", err)
 	}
 	log.Debugf("Connected to data source")
 
-	log.Debugf("Starting Server on port :%s", *addr)
-	microService := service.New(data, nil)
-	err = http.ListenAndServe(":"+*addr, microService)
-	if err != nil {
+	instrumented := metrics.InstrumentDataSource(data, registry)
+	var (
+		ds    = instrumented
+		store = instrumented.(backend.ReportStore)
+	)
+	if cfg.Reports.Dir != "" {
+		fileStore, err := backend.NewFileReportStore(cfg.Reports.Dir)
+		if err != nil {
+			log.Fatalf("Unable to create report store: %v", err)
+		}
+		ds = backend.WithReportStore(instrumented, fileStore)
+		store = fileStore
+	}
+
+	// Background work, such as snapshots and certificate reloads, stops when
+	// the server starts shutting down
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	if cfg.Reports.SnapshotInterval > 0 {
+		log.Debugf("Saving report snapshots every %v", cfg.Reports.SnapshotInterval)
+		go backend.SnapshotReports(background, instrumented, store, cfg.Reports.SnapshotInterval)
+	}
+
+	log.Debugf("Starting Server on %s", cfg.Server.Address)
+	microService := service.New(ds, nil)
+	microService.SetRequestTimeout(cfg.Server.RequestTimeout)
+	microService.SetCacheControl(cfg.Cache.Game, cfg.Cache.Report)
+	microService.SetJobLimits(cfg.Reports.MaxJobs, cfg.Reports.JobTimeout)
+	if cfg.Auth.Enabled() {
+		authenticator, err := newAuthenticator(cfg.Auth)
+		if err != nil {
+			log.Fatalf("Unable to configure authentication: %v", err)
+		}
+		microService.Use(authenticator.Middleware, enforcer(cfg.Auth).Middleware)
+	}
+	microService.Use(rateLimiter(cfg.RateLimits).Middleware)
+	microService.AddHealthCheck("mongo", data)
+	if fileStore, ok := store.(*backend.FileReportStore); ok {
+		microService.AddHealthCheck("report_store", fileStore)
+	}
+	microService.Handle(service.MetricsPath, registry).Methods(http.MethodGet)
+	if err := service.Policy.Check(microService.Router); err != nil {
+		log.Fatalf("Endpoints missing from the access policy: %v", err)
+	}
+	if cfg.CORS.Enabled() {
+		log.Debugf("Allowing cross-origin requests from %v", cfg.CORS.AllowedOrigins)
+		microService.Wrap(cors.New(cors.Config{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			ExposedHeaders:   cfg.CORS.ExposedHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
+		}, microService.Router).Middleware)
+	}
+	microService.Wrap(metrics.Middleware(registry, func(r *http.Request) string {
+		return service.RouteTemplate(microService.Router, r)
+	}))
+
+	// Requests are served with a context which is cancelled if they are still
+	// running once the shutdown grace period is over
+	requests, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	server := &http.Server{
+		Addr:         cfg.Server.Address,
+		Handler:      microService,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		BaseContext:  func(net.Listener) context.Context { return requests },
+	}
+
+	serveErr := make(chan error, 1)
+	if cfg.Server.TLS.Enabled() {
+		reloader, err := tlsconfig.NewCertReloader(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+		if err != nil {
+			log.Fatalf("Unable to read TLS certificate: %v", err)
+		}
+		go reloader.Watch(background, cfg.Server.TLS.ReloadInterval)
+
+		server.TLSConfig, err = tlsconfig.Server(reloader, cfg.Server.TLS.ClientCAFile)
+		if err != nil {
+			log.Fatalf("Unable to configure TLS: %v", err)
+		}
+
+		log.Debugf("Serving HTTPS, client certificates required: %v", cfg.Server.TLS.ClientCAFile != "")
+		go func() {
+			serveErr <- server.ListenAndServeTLS("", "")
+		}()
+	} else {
+		go func() {
+			serveErr <- server.ListenAndServe()
+		}()
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
 		log.Fatalf("Error running server: %v", err)
+	case sig := <-signals:
+		log.Infof("Received %v, shutting down", sig)
+	}
+
+	stopBackground()
+	microService.CancelJobs()
+	shutdown(server, cfg.Server.ShutdownGrace, cancelRequests)
+
+	if err := data.Close(); err != nil {
+		log.Warnf("Unable to disconnect from data source: %v", err)
+	}
+	log.Infof("Server stopped")
+}
+
+// newAuthenticator creates an authenticator accepting the configured API keys
+// and bearer tokens
+func newAuthenticator(authConfig config.AuthConfig) (*auth.Authenticator, error) {
+	var verifier *auth.JWTVerifier
+	if authConfig.JWT.Enabled() {
+		var rsaKey *rsa.PublicKey
+		if authConfig.JWT.RSAPublicKeyFile != "" {
+			data, err := ioutil.ReadFile(authConfig.JWT.RSAPublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+
+			if rsaKey, err = auth.ParseRSAPublicKey(data); err != nil {
+				return nil, err
+			}
+		}
+
+		verifier = auth.NewJWTVerifier([]byte(authConfig.JWT.HMACSecret), rsaKey, authConfig.JWT.Issuer, authConfig.JWT.Audience)
+	}
+
+	log.Debugf("Authenticating with %d API keys, bearer tokens accepted: %v", len(authConfig.APIKeys), verifier != nil)
+	return auth.New(authConfig.APIKeys, verifier), nil
+}
+
+// enforcer creates an enforcer of the service's access policy with the
+// configured roles. The config has been validated so the roles are known to
+// parse.
+func enforcer(authConfig config.AuthConfig) *rbac.Enforcer {
+	roles := make(map[string]rbac.Role, len(authConfig.Roles))
+	for name, role := range authConfig.Roles {
+		roles[name], _ = rbac.ParseRole(role)
+	}
+
+	anonymous := rbac.Anyone
+	if authConfig.AnonymousRole != "" {
+		anonymous, _ = rbac.ParseRole(authConfig.AnonymousRole)
+	}
+
+	log.Debugf("Enforcing access policy, anonymous role: %v", anonymous)
+	return rbac.NewEnforcer(service.Policy, roles, anonymous)
+}
+
+// rateLimiter creates a limiter for the groups of endpoints with a limit. The
+// config has been validated so the limits are known to parse.
+func rateLimiter(limits config.RateLimitConfig) *ratelimit.Limiter {
+	var rules []ratelimit.Rule
+	add := func(name, routes, limit string, exclude ...string) {
+		if limit == "" {
+			return
+		}
+
+		parsed, _ := ratelimit.ParseLimit(limit)
+		rules = append(rules, ratelimit.Rule{Name: name, RoutePrefix: routes, Exclude: exclude, Limit: parsed})
+		log.Debugf("Limiting %s to %v per client", routes, parsed)
+	}
+	// Clients poll their report jobs, which is cheap, until they finish.
+	// Starting a job is still limited.
+	add("report", service.ReportRoutes, limits.Report, service.ReportJobRoutes)
+	add("game", service.GameRoutes, limits.Game)
+
+	return ratelimit.New(ratelimit.NewMemoryStore(), rules...)
+}
+
+// shutdown stops server accepting connections and waits for in-flight
+// requests to finish. Requests still running after the grace period, such as
+// long reports, are cancelled and their connections closed.
+func shutdown(server *http.Server, grace time.Duration, cancelRequests context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Warnf("Requests still in flight after %v, cancelling them: %v", grace, err)
+		cancelRequests()
+		server.Close()
 	}
 }
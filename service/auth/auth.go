@@ -0,0 +1,177 @@
+// Package auth authenticates clients of the service with static API keys or
+// JWT bearer tokens, placing the authenticated Principal on the request's
+// context.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+)
+
+// APIKeyHeader is the header clients send their API key in
+const APIKeyHeader = "X-API-Key"
+
+// The ways a principal can be authenticated.
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal is an authenticated client.
+type Principal struct {
+	// Subject names the client, such as the name of an API key or a token's
+	// sub claim
+	Subject string
+	// Method is MethodAPIKey or MethodJWT
+	Method string
+	// Roles are the roles claimed by a token
+	Roles []string `json:",omitempty"`
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying principal
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal set by WithPrincipal, if there is one
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+var errUnknownAPIKey = errors.New("Unknown API key")
+
+// apiKey is a static API key. Only a hash of the key is kept.
+type apiKey struct {
+	subject string
+	hash    [sha256.Size]byte
+}
+
+// Authenticator is middleware which authenticates requests. Requests without
+// credentials are let through anonymously unless their method can change
+// state, see Safe, so that reads stay public while writes are protected.
+// Requests with invalid credentials are always rejected.
+type Authenticator struct {
+	apiKeys  []apiKey
+	verifier *JWTVerifier
+	now      func() time.Time
+}
+
+// New creates an authenticator accepting the API keys, keyed by the subject
+// they authenticate, and tokens accepted by verifier, which may be nil
+func New(apiKeys map[string]string, verifier *JWTVerifier) *Authenticator {
+	authenticator := &Authenticator{
+		verifier: verifier,
+		now:      time.Now,
+	}
+
+	for subject, key := range apiKeys {
+		authenticator.apiKeys = append(authenticator.apiKeys, apiKey{
+			subject: subject,
+			hash:    sha256.Sum256([]byte(key)),
+		})
+	}
+
+	return authenticator
+}
+
+// Safe reports whether requests with the given method only read data
+func Safe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	return false
+}
+
+// Middleware authenticates the request and places the principal on its
+// context
+func (authenticator *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, hasCredentials, err := authenticator.authenticate(r)
+		switch {
+		case err != nil:
+			logging.FromContext(r.Context()).Debugf("Authentication failed: %v", err)
+			unauthenticated(w, r, `Bearer error="invalid_token"`, "The credentials sent are not valid")
+			return
+		case !hasCredentials && !Safe(r.Method):
+			unauthenticated(w, r, "Bearer", r.Method+" requests require an API key or bearer token")
+			return
+		case hasCredentials:
+			ctx := WithPrincipal(r.Context(), principal)
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).WithField("principal", principal.Subject))
+			r = r.WithContext(ctx)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate checks the request's credentials, reporting whether it had
+// any. API keys are checked before bearer tokens.
+func (authenticator *Authenticator) authenticate(r *http.Request) (Principal, bool, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		principal, err := authenticator.apiKeyPrincipal(key)
+		return principal, true, err
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return Principal{}, false, nil
+	}
+
+	const bearer = "bearer "
+	if len(authorization) <= len(bearer) || !strings.EqualFold(authorization[:len(bearer)], bearer) {
+		return Principal{}, true, invalidToken("not a bearer token")
+	}
+
+	if authenticator.verifier == nil {
+		return Principal{}, true, invalidToken("bearer tokens aren't accepted")
+	}
+
+	claims, err := authenticator.verifier.Verify(authorization[len(bearer):], authenticator.now())
+	if err != nil {
+		return Principal{}, true, err
+	}
+
+	return Principal{Subject: claims.Subject, Method: MethodJWT, Roles: claims.Roles}, true, nil
+}
+
+// apiKeyPrincipal finds the key's subject. Every key is compared, in constant
+// time, so that the time taken doesn't reveal anything about the keys.
+func (authenticator *Authenticator) apiKeyPrincipal(key string) (Principal, error) {
+	hash := sha256.Sum256([]byte(key))
+
+	var (
+		principal Principal
+		found     bool
+	)
+	for _, k := range authenticator.apiKeys {
+		if subtle.ConstantTimeCompare(hash[:], k.hash[:]) == 1 {
+			principal = Principal{Subject: k.subject, Method: MethodAPIKey}
+			found = true
+		}
+	}
+
+	if !found {
+		return principal, errUnknownAPIKey
+	}
+
+	return principal, nil
+}
+
+func unauthenticated(w http.ResponseWriter, r *http.Request, challenge, detail string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthenticated, detail)
+}
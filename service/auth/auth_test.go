@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+var hmacSecret = []byte("test-secret")
+
+func segment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Unable to encode token: %v", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signHS256 creates a token with the given claims signed with secret
+func signHS256(t *testing.T, secret []byte, claims interface{}) string {
+	signed := segment(t, jwtHeader{Alg: AlgHS256, Typ: "JWT"}) + "." + segment(t, claims)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, claims interface{}) string {
+	signed := segment(t, jwtHeader{Alg: AlgRS256, Typ: "JWT"}) + "." + segment(t, claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Unable to sign token: %v", err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+
+	der, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	publicKey, err := ParseRSAPublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatalf("Unable to parse public key: %v", err)
+	}
+
+	verifier := NewJWTVerifier(hmacSecret, publicKey, "issuer", "games")
+	valid := map[string]interface{}{
+		"sub": "alice",
+		"iss": "issuer",
+		"aud": []string{"other", "games"},
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := make(map[string]interface{})
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+
+	// Signing with the public key as an HMAC secret must not pass for RS256
+	confused := segment(t, jwtHeader{Alg: AlgHS256}) + "." + segment(t, valid)
+	mac := hmac.New(sha256.New, der)
+	mac.Write([]byte(confused))
+	confused += "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "HS256", token: signHS256(t, hmacSecret, valid)},
+		{name: "RS256", token: signRS256(t, rsaKey, valid)},
+		{name: "Single audience", token: signHS256(t, hmacSecret, with("aud", "games"))},
+		{name: "Within clock skew", token: signHS256(t, hmacSecret, with("exp", now.Add(-10*time.Second).Unix()))},
+		{name: "Wrong secret", token: signHS256(t, []byte("other"), valid), wantErr: true},
+		{name: "Algorithm confusion", token: confused, wantErr: true},
+		{name: "No algorithm", token: segment(t, jwtHeader{Alg: "none"}) + "." + segment(t, valid) + ".", wantErr: true},
+		{name: "Expired", token: signHS256(t, hmacSecret, with("exp", now.Add(-time.Hour).Unix())), wantErr: true},
+		{name: "No expiry", token: signHS256(t, hmacSecret, with("exp", 0)), wantErr: true},
+		{name: "Not valid yet", token: signHS256(t, hmacSecret, with("nbf", now.Add(time.Hour).Unix())), wantErr: true},
+		{name: "Wrong issuer", token: signHS256(t, hmacSecret, with("iss", "someone")), wantErr: true},
+		{name: "Wrong audience", token: signHS256(t, hmacSecret, with("aud", "other")), wantErr: true},
+		{name: "No subject", token: signHS256(t, hmacSecret, with("sub", "")), wantErr: true},
+		{name: "Malformed", token: "not.a-token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token, now)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, ErrInvalidToken), "Expected an invalid token, got %v", err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "alice", claims.Subject)
+		})
+	}
+}
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	now := time.Now()
+	authenticator := New(map[string]string{"reporting": "key-1"}, NewJWTVerifier(hmacSecret, nil, "", ""))
+	authenticator.now = func() time.Time { return now }
+
+	handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := FromContext(r.Context())
+		json.NewEncoder(w).Encode(principal)
+	}))
+
+	token := signHS256(t, hmacSecret, map[string]interface{}{
+		"sub":   "alice",
+		"exp":   now.Add(time.Hour).Unix(),
+		"roles": []string{"editor"},
+	})
+
+	tests := []struct {
+		name          string
+		method        string
+		headers       map[string]string
+		wantStatus    int
+		wantPrincipal Principal
+	}{
+		{
+			name:       "Anonymous read",
+			method:     http.MethodGet,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Anonymous write",
+			method:     http.MethodPost,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "API key",
+			method:        http.MethodPost,
+			headers:       map[string]string{APIKeyHeader: "key-1"},
+			wantStatus:    http.StatusOK,
+			wantPrincipal: Principal{Subject: "reporting", Method: MethodAPIKey},
+		},
+		{
+			name:       "Unknown API key",
+			method:     http.MethodGet,
+			headers:    map[string]string{APIKeyHeader: "key-2"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:          "Bearer token",
+			method:        http.MethodDelete,
+			headers:       map[string]string{"Authorization": "Bearer " + token},
+			wantStatus:    http.StatusOK,
+			wantPrincipal: Principal{Subject: "alice", Method: MethodJWT, Roles: []string{"editor"}},
+		},
+		{
+			name:       "Invalid bearer token",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Authorization": "Bearer " + token + "x"},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Basic auth",
+			method:     http.MethodGet,
+			headers:    map[string]string{"Authorization": "Basic YWxpY2U6cGFzcw=="},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/games/report/jobs", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, tt.wantStatus, resp.Code)
+
+			if tt.wantStatus != http.StatusOK {
+				var body problem.Problem
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, problem.CodeUnauthenticated, body.Code)
+				assert.NotEmpty(t, resp.Header().Get("WWW-Authenticate"))
+				return
+			}
+
+			var principal Principal
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&principal))
+			assert.Equal(t, tt.wantPrincipal, principal)
+		})
+	}
+}
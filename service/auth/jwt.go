@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The JWT signing algorithms which can be verified.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+// clockSkew is how far a token's times may be off from the server's clock
+const clockSkew = 30 * time.Second
+
+// ErrInvalidToken is returned for tokens which are malformed, signed with an
+// unknown key or algorithm, or have expired.
+var ErrInvalidToken = errors.New("Invalid token")
+
+// Claims are the registered JWT claims checked by a JWTVerifier.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	// Roles is a private claim listing the roles granted to the subject
+	Roles []string `json:"roles,omitempty"`
+}
+
+// audience is the aud claim, which may be a single string or a list
+type audience []string
+
+func (aud *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*aud = list
+
+	return nil
+}
+
+func (aud audience) contains(s string) bool {
+	for _, a := range aud {
+		if a == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// JWTVerifier verifies JWT bearer tokens signed with local keys. Each key is
+// only accepted for its own algorithm, so a public RSA key can't be used as an
+// HMAC secret.
+type JWTVerifier struct {
+	hmacSecret []byte
+	rsaKey     *rsa.PublicKey
+	issuer     string
+	audience   string
+}
+
+// NewJWTVerifier creates a verifier accepting tokens signed with HS256 using
+// hmacSecret and with RS256 using rsaKey. Either may be empty to disable that
+// algorithm. If issuer or audience are set tokens must have matching claims.
+func NewJWTVerifier(hmacSecret []byte, rsaKey *rsa.PublicKey, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		hmacSecret: hmacSecret,
+		rsaKey:     rsaKey,
+		issuer:     issuer,
+		audience:   audience,
+	}
+}
+
+// ParseRSAPublicKey parses a PEM encoded PKIX public key or certificate
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("No PEM data found")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key = cert.PublicKey
+	default:
+		var err error
+		if key, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, err
+		}
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Key is a %T, not an RSA public key", key)
+	}
+
+	return rsaKey, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// Verify checks the token's signature and times, returning its claims. Any
+// failure is reported as ErrInvalidToken, wrapped with the reason.
+func (verifier *JWTVerifier) Verify(token string, now time.Time) (Claims, error) {
+	var claims Claims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return claims, invalidToken("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return claims, invalidToken("malformed header")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, invalidToken("malformed signature")
+	}
+
+	if err := verifier.verifySignature(header.Alg, parts[0]+"."+parts[1], signature); err != nil {
+		return claims, err
+	}
+
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return claims, invalidToken("malformed claims")
+	}
+
+	return claims, verifier.checkClaims(claims, now)
+}
+
+func (verifier *JWTVerifier) verifySignature(alg, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+
+	switch {
+	case alg == AlgHS256 && len(verifier.hmacSecret) > 0:
+		mac := hmac.New(sha256.New, verifier.hmacSecret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return invalidToken("bad signature")
+		}
+	case alg == AlgRS256 && verifier.rsaKey != nil:
+		if err := rsa.VerifyPKCS1v15(verifier.rsaKey, crypto.SHA256, digest[:], signature); err != nil {
+			return invalidToken("bad signature")
+		}
+	default:
+		return invalidToken(fmt.Sprintf("algorithm %q not accepted", alg))
+	}
+
+	return nil
+}
+
+func (verifier *JWTVerifier) checkClaims(claims Claims, now time.Time) error {
+	switch {
+	case claims.Subject == "":
+		return invalidToken("no subject")
+	case claims.ExpiresAt == 0:
+		return invalidToken("no expiry")
+	case now.Add(-clockSkew).After(time.Unix(claims.ExpiresAt, 0)):
+		return invalidToken("expired")
+	case claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)):
+		return invalidToken("not valid yet")
+	case verifier.issuer != "" && claims.Issuer != verifier.issuer:
+		return invalidToken("wrong issuer")
+	case verifier.audience != "" && !claims.Audience.contains(verifier.audience):
+		return invalidToken("wrong audience")
+	}
+
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func invalidToken(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, reason)
+}
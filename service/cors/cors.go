@@ -0,0 +1,196 @@
+// Package cors lets browser clients on other origins call the service by
+// answering CORS preflight requests and adding the CORS headers to responses.
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/service/routing"
+	"github.com/gorilla/mux"
+)
+
+// AnyOrigin allows requests from every origin when in Config.AllowedOrigins
+const AnyOrigin = "*"
+
+// The request headers sent with CORS requests.
+const (
+	originHeader         = "Origin"
+	requestMethodHeader  = "Access-Control-Request-Method"
+	requestHeadersHeader = "Access-Control-Request-Headers"
+)
+
+// Config is which cross-origin requests are allowed.
+type Config struct {
+	// AllowedOrigins are the origins, such as https://example.com, which can
+	// make requests, or AnyOrigin. Case and a trailing slash are ignored.
+	AllowedOrigins []string
+	// AllowedMethods limits the methods allowed in preflight requests. The
+	// methods the route has are always checked as well.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers clients can send
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers, besides the CORS safelisted
+	// ones, which clients can read
+	ExposedHeaders []string
+	// AllowCredentials allows requests with cookies or an Authorization
+	// header. It can't be used with AnyOrigin.
+	AllowCredentials bool
+	// MaxAge is how long clients can cache the response to a preflight
+	// request
+	MaxAge time.Duration
+}
+
+// CORS is middleware which handles cross-origin requests to a router's
+// routes. It must wrap the router, rather than be added with Use, so that it
+// sees preflight requests, which don't match any route.
+type CORS struct {
+	config    Config
+	router    *mux.Router
+	origins   map[string]bool
+	anyOrigin bool
+	methods   map[string]bool
+	headers   map[string]bool
+	anyHeader bool
+}
+
+// New creates CORS middleware for the routes of router
+func New(config Config, router *mux.Router) *CORS {
+	c := &CORS{
+		config:  config,
+		router:  router,
+		origins: make(map[string]bool, len(config.AllowedOrigins)),
+		methods: make(map[string]bool, len(config.AllowedMethods)),
+		headers: make(map[string]bool, len(config.AllowedHeaders)),
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		if origin == AnyOrigin {
+			c.anyOrigin = true
+		}
+		c.origins[normaliseOrigin(origin)] = true
+	}
+	for _, method := range config.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+	for _, header := range config.AllowedHeaders {
+		if header == "*" {
+			c.anyHeader = true
+		}
+		c.headers[http.CanonicalHeaderKey(header)] = true
+	}
+
+	return c
+}
+
+// Middleware answers preflight requests for the router's routes and adds the
+// CORS headers to the responses to other requests from allowed origins.
+// Requests for paths without a route are passed on, so they get the router's
+// usual not found response.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get(originHeader)
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", originHeader)
+		if !c.allowedOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get(requestMethodHeader) != "" {
+			c.preflight(w, r, next)
+			return
+		}
+
+		c.setOrigin(header, origin)
+		if len(c.config.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(c.config.ExposedHeaders, ", "))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preflight tells the client which methods the route has and which of the
+// requested headers it can send. If the method or headers aren't allowed the
+// client won't make the request.
+func (c *CORS) preflight(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	methods := c.allowedMethods(r)
+	if len(methods) == 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	header := w.Header()
+	header.Add("Vary", requestMethodHeader)
+	header.Add("Vary", requestHeadersHeader)
+
+	c.setOrigin(header, r.Header.Get(originHeader))
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if headers := c.allowedHeaders(r.Header.Get(requestHeadersHeader)); len(headers) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if c.config.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.config.MaxAge.Seconds())))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORS) allowedOrigin(origin string) bool {
+	return c.anyOrigin || c.origins[normaliseOrigin(origin)]
+}
+
+// normaliseOrigin lower cases origin and removes any trailing slash, which
+// browsers never send but is easily added to a configured origin
+func normaliseOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(origin), "/")
+}
+
+// setOrigin allows the origin to read the response. Browsers ignore a
+// wildcard for requests with credentials, so the origin is echoed instead.
+func (c *CORS) setOrigin(header http.Header, origin string) {
+	if c.anyOrigin && !c.config.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", AnyOrigin)
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if c.config.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// allowedMethods lists the methods which the route at the requested path has
+// and the config allows
+func (c *CORS) allowedMethods(r *http.Request) []string {
+	allowed := make([]string, 0)
+	for _, method := range routing.AllowedMethods(c.router, r) {
+		if len(c.methods) == 0 || c.methods[method] {
+			allowed = append(allowed, method)
+		}
+	}
+
+	return allowed
+}
+
+// allowedHeaders filters the comma separated list of headers requested by a
+// preflight request down to those which are allowed
+func (c *CORS) allowedHeaders(requested string) []string {
+	allowed := make([]string, 0)
+	for _, name := range strings.Split(requested, ",") {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name != "" && (c.anyHeader || c.headers[name]) {
+			allowed = append(allowed, name)
+		}
+	}
+
+	return allowed
+}
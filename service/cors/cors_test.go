@@ -0,0 +1,167 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/service/routing"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRouter() *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	router := mux.NewRouter()
+	games := router.PathPrefix("/games").Subrouter()
+	games.HandleFunc("/{id:[0-9]+}", ok).Methods(http.MethodGet)
+	games.HandleFunc("/report/jobs", ok).Methods(http.MethodPost)
+	games.HandleFunc("/report/jobs/{id}", ok).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
+	routing.SetErrorHandlers(router)
+
+	return router
+}
+
+var testConfig = Config{
+	AllowedOrigins: []string{"https://app.example.com"},
+	AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodDelete},
+	AllowedHeaders: []string{"Authorization", "X-API-Key"},
+	ExposedHeaders: []string{"X-Request-ID"},
+	MaxAge:         10 * time.Minute,
+}
+
+func TestCORS_Middleware(t *testing.T) {
+	withCredentials := testConfig
+	withCredentials.AllowCredentials = true
+
+	anyOrigin := testConfig
+	anyOrigin.AllowedOrigins = []string{AnyOrigin}
+
+	trailingSlash := testConfig
+	trailingSlash.AllowedOrigins = []string{"https://App.example.com/"}
+
+	tests := []struct {
+		name        string
+		config      Config
+		method      string
+		path        string
+		headers     map[string]string
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "Preflight",
+			config:     testConfig,
+			method:     http.MethodOptions,
+			path:       "/games/report/jobs/abc",
+			headers:    map[string]string{"Origin": "https://app.example.com", requestMethodHeader: "DELETE", requestHeadersHeader: "x-api-key, x-other"},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Methods":     "DELETE, GET",
+				"Access-Control-Allow-Headers":     "X-Api-Key",
+				"Access-Control-Max-Age":           "600",
+				"Access-Control-Allow-Credentials": "",
+			},
+		},
+		{
+			name:        "Preflight for a route's only method",
+			config:      testConfig,
+			method:      http.MethodOptions,
+			path:        "/games/report/jobs",
+			headers:     map[string]string{"Origin": "https://app.example.com", requestMethodHeader: "POST"},
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Methods": "POST"},
+		},
+		{
+			name:       "Preflight with credentials",
+			config:     withCredentials,
+			method:     http.MethodOptions,
+			path:       "/games/1",
+			headers:    map[string]string{"Origin": "https://app.example.com", requestMethodHeader: "GET"},
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			name:        "Preflight for unknown path",
+			config:      testConfig,
+			method:      http.MethodOptions,
+			path:        "/players/1",
+			headers:     map[string]string{"Origin": "https://app.example.com", requestMethodHeader: "GET"},
+			wantStatus:  http.StatusNotFound,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:        "Preflight from other origin",
+			config:      testConfig,
+			method:      http.MethodOptions,
+			path:        "/games/1",
+			headers:     map[string]string{"Origin": "https://evil.example.com", requestMethodHeader: "GET"},
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			name:       "Request",
+			config:     testConfig,
+			method:     http.MethodGet,
+			path:       "/games/1",
+			headers:    map[string]string{"Origin": "https://app.example.com"},
+			wantStatus: http.StatusOK,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://app.example.com",
+				"Access-Control-Expose-Headers": "X-Request-ID",
+				"Vary":                          "Origin",
+			},
+		},
+		{
+			name:        "Request from origin configured with a trailing slash",
+			config:      trailingSlash,
+			method:      http.MethodGet,
+			path:        "/games/1",
+			headers:     map[string]string{"Origin": "https://app.example.com"},
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"},
+		},
+		{
+			name:        "Request from any origin",
+			config:      anyOrigin,
+			method:      http.MethodGet,
+			path:        "/games/1",
+			headers:     map[string]string{"Origin": "https://other.example.com"},
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		{
+			name:        "Same origin request",
+			config:      testConfig,
+			method:      http.MethodGet,
+			path:        "/games/1",
+			wantStatus:  http.StatusOK,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := testRouter()
+			handler := New(tt.config, router).Middleware(router)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.wantStatus, resp.Code)
+			for name, want := range tt.wantHeaders {
+				assert.Equal(t, want, resp.Header().Get(name), name)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package export writes tabular data as CSV or as an XLSX spreadsheet so that
+// service responses can be opened by spreadsheet software.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Format is a file format a table can be exported as
+type Format string
+
+// The supported export formats
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// The media types of the export formats
+const (
+	CSVContentType  = "text/csv"
+	XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// ParseFormat converts s, a format name such as "csv", into a Format
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case CSV, XLSX:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("Invalid format %q, expected one of %s or %s", s, CSV, XLSX)
+}
+
+// FormatForMediaType returns the export format with the given media type, if
+// there is one
+func FormatForMediaType(mediaType string) (Format, bool) {
+	switch strings.ToLower(mediaType) {
+	case CSVContentType:
+		return CSV, true
+	case XLSXContentType:
+		return XLSX, true
+	}
+
+	return "", false
+}
+
+// ContentType returns the media type of the format
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return XLSXContentType
+	}
+
+	return CSVContentType
+}
+
+// Write writes the table to w in the format. name is used for the sheet name of
+// spreadsheets.
+func (f Format) Write(w io.Writer, name string, table Table) error {
+	if f == XLSX {
+		return WriteXLSX(w, name, table)
+	}
+
+	return WriteCSV(w, table)
+}
+
+// Table is a header row of column names followed by rows of values. Values may
+// be strings, bools, ints or float64s.
+type Table struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Select returns a copy of the table with only the named columns, in the
+// order they are given. An empty selection keeps every column.
+func (table Table) Select(columns []string) (Table, error) {
+	if len(columns) == 0 {
+		return table, nil
+	}
+
+	index := make(map[string]int, len(table.Columns))
+	for i, column := range table.Columns {
+		index[column] = i
+	}
+
+	picked := make([]int, len(columns))
+	for i, column := range columns {
+		j, ok := index[column]
+		if !ok {
+			return table, fmt.Errorf("Unknown column %q, expected one of %s",
+				column, strings.Join(table.Columns, ", "))
+		}
+		picked[i] = j
+	}
+
+	selected := Table{
+		Columns: columns,
+		Rows:    make([][]interface{}, len(table.Rows)),
+	}
+	for i, row := range table.Rows {
+		selected.Rows[i] = make([]interface{}, len(picked))
+		for j, k := range picked {
+			selected.Rows[i][j] = row[k]
+		}
+	}
+
+	return selected, nil
+}
+
+// WriteCSV writes the table as comma separated values with a header row
+func WriteCSV(w io.Writer, table Table) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(table.Columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(table.Columns))
+	for _, row := range table.Rows {
+		for i, value := range row {
+			text, numeric := formatValue(value)
+			if !numeric {
+				text = escapeFormula(text)
+			}
+			record[i] = text
+		}
+
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// escapeFormula stops spreadsheets from running text, such as a game title
+// or user name, as a formula when the CSV is opened, by prefixing text which
+// starts like one with a quote
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+
+	return text
+}
+
+// formatValue converts a table value to text, reporting whether it is a number
+func formatValue(value interface{}) (text string, numeric bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, false
+	case bool:
+		return strconv.FormatBool(v), false
+	case int:
+		return strconv.Itoa(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+
+	return fmt.Sprint(value), false
+}
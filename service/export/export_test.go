@@ -0,0 +1,112 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testTable = Table{
+	Columns: []string{"title", "likes", "average", "platform"},
+	Rows: [][]interface{}{
+		{"Dummy", 6, 1.5, "PC; XBOX"},
+		{"Quotes \"&\" <tags>", 0, 0.0, ""},
+	},
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCSV(&buf, testTable))
+
+	assert.Equal(t, "title,likes,average,platform\n"+
+		"Dummy,6,1.5,PC; XBOX\n"+
+		"\"Quotes \"\"&\"\" <tags>\",0,0,\n", buf.String())
+}
+
+func TestWriteCSV_formulas(t *testing.T) {
+	table := Table{
+		Columns: []string{"title", "likes", "average"},
+		Rows: [][]interface{}{
+			{"=HYPERLINK(\"http://example.com\")", -2, -0.5},
+			{"+1", 0, 0.0},
+			{"-1", 0, 0.0},
+			{"@SUM(A1)", 0, 0.0},
+			{"\tTab", 0, 0.0},
+			{"\rReturn", 0, 0.0},
+			{"Safe = 1", 0, 0.0},
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCSV(&buf, table))
+
+	assert.Equal(t, "title,likes,average\n"+
+		"\"'=HYPERLINK(\"\"http://example.com\"\")\",-2,-0.5\n"+
+		"'+1,0,0\n"+
+		"'-1,0,0\n"+
+		"'@SUM(A1),0,0\n"+
+		"'\tTab,0,0\n"+
+		"\"'\rReturn\",0,0\n"+
+		"Safe = 1,0,0\n", buf.String(), "Text should be escaped but numbers left alone")
+}
+
+func TestTable_Select(t *testing.T) {
+	selected, err := testTable.Select([]string{"likes", "title"})
+	assert.NoError(t, err)
+	assert.Equal(t, Table{
+		Columns: []string{"likes", "title"},
+		Rows: [][]interface{}{
+			{6, "Dummy"},
+			{0, "Quotes \"&\" <tags>"},
+		},
+	}, selected)
+
+	_, err = testTable.Select([]string{"missing"})
+	assert.Error(t, err, "Unknown columns should be rejected")
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteXLSX(&buf, "Games: all", testTable))
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Workbook should be a zip archive: %v", err)
+	}
+
+	parts := make(map[string]string)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Unable to open %s: %v", f.Name, err)
+		}
+		content, _ := ioutil.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		assert.Contains(t, parts, name)
+	}
+
+	assert.Contains(t, parts["xl/workbook.xml"], `name="Games- all"`)
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c r="A1" t="inlineStr"><is><t xml:space="preserve">title</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="B2"><v>6</v></c>`)
+	assert.Contains(t, sheet, `<c r="C2"><v>1.5</v></c>`)
+	assert.Contains(t, sheet, `Quotes &#34;&amp;&#34; &lt;tags&gt;`)
+	assert.True(t, strings.HasSuffix(sheet, "</sheetData></worksheet>"))
+}
+
+func Test_columnName(t *testing.T) {
+	assert.Equal(t, "A", columnName(0))
+	assert.Equal(t, "Z", columnName(25))
+	assert.Equal(t, "AA", columnName(26))
+	assert.Equal(t, "AZ", columnName(51))
+	assert.Equal(t, "BA", columnName(52))
+}
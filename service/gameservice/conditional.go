@@ -0,0 +1,88 @@
+package gameservice
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// encodeJSON encodes v as it would be written by a json.Encoder
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// strongETag is an entity tag for body, changing whenever any byte of it does
+func strongETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// writeConditional responds with body, which was last modified at
+// lastModified, unless the client already has it, in which case it responds
+// with 304 Not Modified. If-None-Match is checked against body's ETag. Likes
+// can change without a new comment, so If-Modified-Since is only used by
+// clients which don't send If-None-Match. A zero lastModified is unknown.
+func writeConditional(w http.ResponseWriter, r *http.Request, body []byte, lastModified time.Time, cacheControl string) {
+	etag := strongETag(body)
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if cacheControl != "" {
+		header.Set("Cache-Control", cacheControl)
+	}
+	// Exports are chosen by the Accept header, so share the URL
+	header.Add("Vary", "Accept")
+
+	if notModified(r, etag, lastModified) {
+		header.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Write(body)
+}
+
+// notModified reports whether the request's conditional headers show the
+// client has the current response
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		return etagMatches(match, etag)
+	}
+
+	since := r.Header.Get("If-Modified-Since")
+	if since == "" || lastModified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(since)
+	if err != nil {
+		return false
+	}
+
+	return !lastModified.Truncate(time.Second).After(t)
+}
+
+// etagMatches reports whether etag is in the If-None-Match list, which uses
+// the weak comparison so W/ prefixes are ignored
+func etagMatches(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
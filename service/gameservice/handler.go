@@ -1,11 +1,19 @@
 package gameservice
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/DHBosworth/technichalexercise/service/routing"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 )
@@ -17,8 +25,12 @@ func New(ds backend.GameDataSource, router *mux.Router) *Handler {
 	}
 
 	gameService := &Handler{
-		ds:     ds,
-		Router: router,
+		ds:                 ds,
+		Router:             router,
+		jobs:               newReportJobs(),
+		requestTimeout:     DefaultRequestTimeout,
+		gameCacheControl:   DefaultGameCacheControl,
+		reportCacheControl: DefaultReportCacheControl,
 	}
 
 	gameService.RegisterEndpoints()
@@ -26,21 +38,62 @@ func New(ds backend.GameDataSource, router *mux.Router) *Handler {
 	return gameService
 }
 
+// CancelJobs cancels every report job which is still running, such as when
+// the service is shutting down. Cancelled jobs can still be fetched.
+func (gs *Handler) CancelJobs() {
+	gs.jobs.cancelAll()
+}
+
+// DefaultRequestTimeout limits how long a request waits for the data source
+// unless changed with SetRequestTimeout. Reports which take longer can be run
+// in the background as a report job.
+const DefaultRequestTimeout = 10 * time.Second
+
+// SetRequestTimeout changes how long a request waits for the data source
+func (gs *Handler) SetRequestTimeout(timeout time.Duration) {
+	gs.requestTimeout = timeout
+}
+
+// SetJobLimits changes how many report jobs can run at once and how long each
+// can run for, see DefaultMaxReportJobs
+func (gs *Handler) SetJobLimits(max int, timeout time.Duration) {
+	gs.jobs.mu.Lock()
+	defer gs.jobs.mu.Unlock()
+
+	gs.jobs.max = max
+	gs.jobs.timeout = timeout
+}
+
+// The Cache-Control headers sent with games and reports unless changed with
+// SetCacheControl. Responses are private as they may depend on who the client
+// authenticated as.
+const (
+	DefaultGameCacheControl   = "private, max-age=60"
+	DefaultReportCacheControl = "private, max-age=300"
+)
+
+// SetCacheControl changes the Cache-Control headers sent with games and
+// reports. An empty value sends no header.
+func (gs *Handler) SetCacheControl(game, report string) {
+	gs.gameCacheControl = game
+	gs.reportCacheControl = report
+}
+
+// requestContext creates the context used to query the data source for r
+func (gs *Handler) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), gs.requestTimeout)
+}
+
 // Handler is the http.Handler for the games service
 type Handler struct {
 	*mux.Router
-	ds backend.GameDataSource
-}
-
-var nonGetMethods = []string{
-	http.MethodHead,
-	http.MethodPost,
-	http.MethodPut,
-	http.MethodPatch,
-	http.MethodDelete,
-	http.MethodConnect,
-	http.MethodOptions,
-	http.MethodTrace,
+	ds             backend.GameDataSource
+	jobs           *reportJobs
+	requestTimeout time.Duration
+	// gameCacheControl and reportCacheControl are sent with the JSON games
+	// and reports, see writeConditional
+	gameCacheControl   string
+	reportCacheControl string
 }
 
 // RegisterEndpoints registers the the game services endpoint handlers with the
@@ -49,83 +102,368 @@ func (gs *Handler) RegisterEndpoints() {
 	log.Debugf("Registering GetGame endpoint")
 	getGamePath := gs.Path("/{id:[0-9]+}")
 	getGamePath.Methods(http.MethodGet).HandlerFunc(gs.getGameEndpoint)
-	// getGamePath.Methods(...nonGetMethods).HandlerFunc(invalidMethod)
+
+	log.Debugf("Registering GameStats endpoint")
+	gameStatsPath := gs.Path("/{id:[0-9]+}/stats")
+	gameStatsPath.Methods(http.MethodGet).HandlerFunc(gs.gameStatsEndpoint)
+
+	log.Debugf("Registering SimilarGames endpoint")
+	gs.Path("/{id:[0-9]+}/similar").Methods(http.MethodGet).HandlerFunc(gs.similarGamesEndpoint)
+
+	log.Debugf("Registering Trending endpoint")
+	gs.Path("/trending").Methods(http.MethodGet).HandlerFunc(gs.trendingEndpoint)
 
 	log.Debugf("Registering Report endpoint")
 	reportPath := gs.Path("/report")
 	reportPath.Methods(http.MethodGet).HandlerFunc(gs.reportEndpoint)
-	// reportPath.Methods(...nonGetMethods).HandlerFunc(invalidMethod)
 
-	gs.NotFoundHandler = http.HandlerFunc(invalidEnpoint)
+	log.Debugf("Registering ReportSections endpoint")
+	gs.Path("/report/sections").Methods(http.MethodGet).HandlerFunc(gs.reportSectionsEndpoint)
+
+	log.Debugf("Registering ReportJob endpoints")
+	gs.Path("/report/jobs").Methods(http.MethodPost).HandlerFunc(gs.startReportJobEndpoint)
+	gs.Path("/report/jobs/{id:[0-9a-f]+}").Methods(http.MethodGet).HandlerFunc(gs.reportJobEndpoint)
+	gs.Path("/report/jobs/{id:[0-9a-f]+}").Methods(http.MethodDelete).HandlerFunc(gs.cancelReportJobEndpoint)
+	gs.Path("/report/jobs/{id:[0-9a-f]+}/result").Methods(http.MethodGet).HandlerFunc(gs.reportJobResultEndpoint)
+
+	log.Debugf("Registering ReportHistory endpoint")
+	reportHistoryPath := gs.Path("/report/history")
+	reportHistoryPath.Methods(http.MethodGet).HandlerFunc(gs.reportHistoryEndpoint)
+
+	log.Debugf("Registering ReportDiff endpoint")
+	reportDiffPath := gs.Path("/report/diff")
+	reportDiffPath.Methods(http.MethodGet).HandlerFunc(gs.reportDiffEndpoint)
+
+	log.Debugf("Registering TimeSeries endpoint")
+	timeSeriesPath := gs.Path("/report/timeseries")
+	timeSeriesPath.Methods(http.MethodGet).HandlerFunc(gs.timeSeriesEndpoint)
+
+	routing.SetErrorHandlers(gs.Router)
+	gs.Use(maxAgeMiddleware)
 }
 
-func invalidMethod(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(backend.Error{
-		Msg: fmt.Sprintf("Method %s invalid", r.Method),
+// maxAgeMiddleware hides the games which aren't suitable for the age given by
+// the optional max_age query parameter from every endpoint, see
+// backend.WithMaxAge. Report snapshots are returned as they were taken.
+func maxAgeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param := r.URL.Query().Get("max_age")
+		if param == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		age, err := strconv.Atoi(param)
+		if err != nil || age < 0 {
+			w.Header().Set("Content-Type", "application/json")
+			badRequestError(w, r, fmt.Errorf("Invalid max_age %q, expected an age of zero or more", param))
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(backend.WithMaxAge(r.Context(), age)))
 	})
 }
 
-// reportEndpoint is the handler for the /report endpoint
+// reportEndpoint is the handler for the /report endpoint. The optional rounding
+// query parameter controls how the averages in the report are presented.
+// The sections query parameter limits the report to the listed sections.
+// Otherwise the report can be exported as CSV or XLSX, see exportFormat, and
+// clients accepting application/x-ndjson are streamed the report a game at a
+// time. JSON reports can be fetched conditionally, see writeConditional.
 func (gs *Handler) reportEndpoint(w http.ResponseWriter, r *http.Request) {
-	log.Debugf("Get report")
+	logging.FromContext(r.Context()).Debugf("Get report")
 
 	w.Header().Set("Content-Type", "application/json")
-	report, err := gs.ds.Report()
+	rounding, err := backend.ParseRounding(r.URL.Query().Get("rounding"))
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	if sectionsParam := r.URL.Query().Get("sections"); sectionsParam != "" {
+		names, err := parseSections(sectionsParam)
+		if err != nil {
+			badRequestError(w, r, err)
+			return
+		}
+
+		gs.reportSections(w, r, names, rounding)
+		return
+	}
+
+	format, isExport, err := exportFormat(r)
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	if !isExport && accepts(r, ndjsonContentType) {
+		gs.streamReport(w, r, rounding)
+		return
+	}
+
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	report, err := gs.ds.Report(ctx)
+	if err != nil {
+		reportError(w, r, err)
+		return
+	}
+
+	if isExport {
+		writeTable(w, r, format, "report", reportTable(report.Rounded(rounding)))
+		return
+	}
+
+	body, err := encodeJSON(report.Rounded(rounding))
 	if err != nil {
-		reportError(w, err)
+		reportError(w, r, err)
 		return
 	}
 
-	enc := json.NewEncoder(w)
-	enc.Encode(report)
+	writeConditional(w, r, body, report.NewestComment, gs.reportCacheControl)
 }
 
-// reportError encodes an error into json format and sets up the http.Response
-func reportError(w http.ResponseWriter, err error) {
-	w.WriteHeader(http.StatusInternalServerError)
-	json.NewEncoder(w).Encode(backend.Error{Msg: err.Error()})
+// timeSeriesEndpoint is the handler for the /report/timeseries endpoint. The
+// interval query parameter sets the width of each bucket and defaults to a day.
+func (gs *Handler) timeSeriesEndpoint(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debugf("Get time series")
+
+	w.Header().Set("Content-Type", "application/json")
+	interval, err := backend.ParseInterval(r.URL.Query().Get("interval"))
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	series, err := gs.ds.TimeSeries(ctx, interval)
+	if err != nil {
+		reportError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(series)
 }
 
-// getGameEndpoint is the handler for the /games/<game_id> endpoint
+// The defaults used by the trending endpoint when the query parameters aren't
+// given
+const (
+	defaultTrendingWindow   = "7d"
+	defaultTrendingHalfLife = "2d"
+	defaultTrendingLimit    = 10
+)
+
+// trendingEndpoint is the handler for the /games/trending endpoint. The window
+// and half_life query parameters control which comments are counted and how
+// quickly they stop counting, and limit the number of games listed.
+func (gs *Handler) trendingEndpoint(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debugf("Get trending games")
+
+	w.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	opts := backend.TrendingOptions{Limit: defaultTrendingLimit}
+
+	var err error
+	opts.Window, err = backend.ParseWindow(queryOrDefault(query, "window", defaultTrendingWindow))
+	if err != nil {
+		badRequestError(w, r, fmt.Errorf("Invalid window: %v", err))
+		return
+	}
+
+	opts.HalfLife, err = backend.ParseWindow(queryOrDefault(query, "half_life", defaultTrendingHalfLife))
+	if err != nil {
+		badRequestError(w, r, fmt.Errorf("Invalid half_life: %v", err))
+		return
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		opts.Limit, err = strconv.Atoi(limitStr)
+		if err != nil || opts.Limit < 0 {
+			badRequestError(w, r, fmt.Errorf("Invalid limit %q, expected a number of zero or more", limitStr))
+			return
+		}
+	}
+
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	trending, err := gs.ds.Trending(ctx, opts)
+	if err != nil {
+		reportError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(trending)
+}
+
+// queryOrDefault returns the named query parameter, or def if it is missing
+func queryOrDefault(query url.Values, name, def string) string {
+	if value := query.Get(name); value != "" {
+		return value
+	}
+
+	return def
+}
+
+// reportError responds with a problem describing a failed data source query
+func reportError(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Error(w, r, http.StatusInternalServerError, problem.CodeDataSourceError, err.Error())
+}
+
+// getGameEndpoint is the handler for the /games/<game_id> endpoint. The game
+// can be exported as CSV or XLSX with a row per comment, see exportFormat. JSON
+// games can be fetched conditionally, see writeConditional.
 func (gs *Handler) getGameEndpoint(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	vars := mux.Vars(r)
 
 	gameID, reqHasID := vars["id"]
 	if !reqHasID {
-		noIDError(w)
+		noIDError(w, r)
+		return
+	}
+
+	logging.FromContext(r.Context()).Debugf("Get Game %s", gameID)
+
+	format, isExport, err := exportFormat(r)
+	if err != nil {
+		badRequestError(w, r, err)
 		return
 	}
 
-	log.Debugf("Get Game %s", gameID)
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	game, err := gs.ds.Game(ctx, gameID)
+	if err != nil {
+		gameError(w, r, gameID, err)
+		return
+	}
+
+	if isExport {
+		writeTable(w, r, format, "game-"+gameID, gameTable(game))
+		return
+	}
 
-	game, err := gs.ds.Game(gameID)
+	body, err := encodeJSON(game)
 	if err != nil {
-		gameNotFoundError(w, gameID, err)
+		reportError(w, r, err)
 		return
 	}
 
-	respEncoder := json.NewEncoder(w)
-	respEncoder.Encode(game)
+	writeConditional(w, r, body, game.NewestComment(), gs.gameCacheControl)
 }
 
-func noIDError(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusBadRequest)
-	json.NewEncoder(w).Encode(backend.Error{
-		Msg: "Invalid request. Require parameter id.",
-	})
+func badRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	problem.Error(w, r, http.StatusBadRequest, problem.CodeInvalidParameter, err.Error())
 }
 
-func gameNotFoundError(w http.ResponseWriter, id string, err error) {
-	enc := json.NewEncoder(w)
-	w.WriteHeader(http.StatusNotFound)
-	if err := enc.Encode(backend.Error{Msg: err.Error()}); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+// defaultTopCommenters is the number of top commenters included in game stats
+// when the top query parameter isn't given
+const defaultTopCommenters = 5
+
+// gameStatsEndpoint is the handler for the /games/<game_id>/stats endpoint. The
+// optional top query parameter limits the number of top commenters and
+// rounding controls how the averages are presented.
+func (gs *Handler) gameStatsEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+
+	gameID, reqHasID := vars["id"]
+	if !reqHasID {
+		noIDError(w, r)
+		return
 	}
 
+	logging.FromContext(r.Context()).Debugf("Get Game stats %s", gameID)
+
+	query := r.URL.Query()
+	top := defaultTopCommenters
+	if topStr := query.Get("top"); topStr != "" {
+		var err error
+		top, err = strconv.Atoi(topStr)
+		if err != nil || top < 0 {
+			badRequestError(w, r, fmt.Errorf("Invalid top %q, expected a number of zero or more", topStr))
+			return
+		}
+	}
+
+	rounding, err := backend.ParseRounding(query.Get("rounding"))
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	stats, err := gs.ds.GameStats(ctx, gameID, top)
+	if err != nil {
+		gameError(w, r, gameID, err)
+		return
+	}
+	stats.GameAverageLikes = stats.GameAverageLikes.Rounded(rounding)
+
+	json.NewEncoder(w).Encode(stats)
 }
 
-func invalidEnpoint(w http.ResponseWriter, r *http.Request) {
+// defaultSimilarGames is the number of similar games listed when the limit
+// query parameter isn't given
+const defaultSimilarGames = 5
+
+// similarGamesEndpoint is the handler for the /games/<game_id>/similar
+// endpoint, recommending games like the given game along with the reasons
+// they were picked
+func (gs *Handler) similarGamesEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+
+	gameID, reqHasID := vars["id"]
+	if !reqHasID {
+		noIDError(w, r)
+		return
+	}
+
+	logging.FromContext(r.Context()).Debugf("Get games similar to %s", gameID)
+
+	limit := defaultSimilarGames
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			badRequestError(w, r, fmt.Errorf("Invalid limit %q, expected a number of zero or more", limitStr))
+			return
+		}
+	}
+
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	similar, err := gs.ds.SimilarGames(ctx, gameID, limit)
+	if err != nil {
+		gameError(w, r, gameID, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(similar)
+}
+
+func noIDError(w http.ResponseWriter, r *http.Request) {
+	problem.Error(w, r, http.StatusBadRequest, problem.CodeMissingID, "Invalid request. Require parameter id.")
+}
+
+// gameError responds to an error getting the game with the given id. Only
+// backend.ErrGameNotFound is a 404, other errors are failures of the data
+// source.
+func gameError(w http.ResponseWriter, r *http.Request, id string, err error) {
+	if !errors.Is(err, backend.ErrGameNotFound) {
+		reportError(w, r, err)
+		return
+	}
 
+	problem.Error(w, r, http.StatusNotFound, problem.CodeGameNotFound, fmt.Sprintf("Game %s not found", id))
 }
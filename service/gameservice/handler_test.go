@@ -1,15 +1,18 @@
 package gameservice
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 	"time"
 
 	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/service/problem"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
@@ -43,6 +46,15 @@ func TestHandler_RegisterEndpoints(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "Has game stats route",
+			gs:   &Handler{Router: mux.NewRouter()},
+			check: func(handler *Handler) {
+				if !hasRoute(handler.Router, "/{id:[0-9]+}/stats") {
+					t.Errorf("Game stats endpoint not registered")
+				}
+			},
+		},
 		{
 			name: "Has report route",
 			gs:   &Handler{Router: mux.NewRouter()},
@@ -52,6 +64,33 @@ func TestHandler_RegisterEndpoints(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "Has time series route",
+			gs:   &Handler{Router: mux.NewRouter()},
+			check: func(handler *Handler) {
+				if !hasRoute(handler.Router, "/report/timeseries") {
+					t.Errorf("Time series endpoint not registered")
+				}
+			},
+		},
+		{
+			name: "Has similar games route",
+			gs:   &Handler{Router: mux.NewRouter()},
+			check: func(handler *Handler) {
+				if !hasRoute(handler.Router, "/{id:[0-9]+}/similar") {
+					t.Errorf("Similar games endpoint not registered")
+				}
+			},
+		},
+		{
+			name: "Has trending route",
+			gs:   &Handler{Router: mux.NewRouter()},
+			check: func(handler *Handler) {
+				if !hasRoute(handler.Router, "/trending") {
+					t.Errorf("Trending endpoint not registered")
+				}
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -101,11 +140,12 @@ type mockGameDataSource struct{}
 
 var mockGames = []backend.Game{
 	{
+		ID:          "1",
 		Title:       "Dummy",
 		Description: "A game that exists solely for testing",
 		By:          "me",
 		Platform:    []string{"PC - obviously"},
-		AgeRating:   "42+",
+		AgeRating:   backend.MustParseAgeRating("42+"),
 		Likes:       42,
 		Comments: []backend.Comment{
 			{
@@ -123,11 +163,12 @@ var mockGames = []backend.Game{
 		},
 	},
 	{
+		ID:          "2",
 		Title:       "Solitary Voyage",
 		Description: "Decsription goes here",
 		By:          "Jimmie Bassett",
 		Platform:    []string{"PC", "XBOX"},
-		AgeRating:   "6+",
+		AgeRating:   backend.MustParseAgeRating("6+"),
 		Likes:       99,
 		Comments: []backend.Comment{
 			{
@@ -140,24 +181,96 @@ var mockGames = []backend.Game{
 	},
 }
 
-func (mockGameDataSource) Game(id string) (game backend.Game, err error) {
+func (mockGameDataSource) Game(ctx context.Context, id string) (game backend.Game, err error) {
 	switch id {
 	case "1":
 		game = mockGames[0]
 	case "2":
 		game = mockGames[1]
 	default:
-		err = fmt.Errorf("Unable to get game")
+		err = fmt.Errorf("Unable to get game: %w", backend.ErrGameNotFound)
 	}
 
 	return game, err
 }
 
-var mockReport = backend.Report{}
+var mockReport = backend.Report{
+	UserWithMostComments: "Jacqueline Dodson",
+	HighestRatedGame:     "Solitary Voyage",
+	AverageLikesPerGame: []backend.GameAverageLikes{
+		{
+			Title:        "Solitary Voyage",
+			Comments:     1,
+			TotalLikes:   9,
+			AverageLikes: 9,
+			MedianLikes:  9,
+			MinLikes:     9,
+			MaxLikes:     9,
+		},
+		{
+			Title:        "Dummy",
+			Comments:     2,
+			TotalLikes:   6,
+			AverageLikes: 3,
+			MedianLikes:  3,
+			MinLikes:     1,
+			MaxLikes:     5,
+			StdDevLikes:  2,
+		},
+		{
+			Title:        "Rounding",
+			Comments:     3,
+			TotalLikes:   4,
+			AverageLikes: 1.3333333333333333,
+			MedianLikes:  1.5,
+			MinLikes:     1,
+			MaxLikes:     2,
+			StdDevLikes:  0.4714045207910317,
+		},
+	},
+}
+
+func (mockGameDataSource) Report(ctx context.Context) (report backend.Report, err error) {
+	return mockReport, nil
+}
+
+func (mockGameDataSource) StreamReport(ctx context.Context, row func(backend.GameAverageLikes) error) (summary backend.Report, err error) {
+	for _, stats := range mockReport.AverageLikesPerGame {
+		if err := row(stats); err != nil {
+			return summary, err
+		}
+	}
+
+	summary = mockReport
+	summary.AverageLikesPerGame = nil
+	return summary, nil
+}
+
+func (mockGameDataSource) ReportSections(ctx context.Context, names []string) (map[string]interface{}, error) {
+	return mockMemoryDataSource().ReportSections(ctx, names)
+}
+
+func mockMemoryDataSource() *backend.MemoryDataSource {
+	return backend.NewMemoryDataSource(map[string]backend.Game{
+		"1": mockGames[0],
+		"2": mockGames[1],
+	})
+}
+
+func (mockGameDataSource) TimeSeries(ctx context.Context, interval backend.Interval) (series backend.TimeSeries, err error) {
+	return mockMemoryDataSource().TimeSeries(ctx, interval)
+}
 
-func (mockGameDataSource) Report() (report backend.Report, err error) {
+func (mockGameDataSource) SimilarGames(ctx context.Context, id string, limit int) ([]backend.SimilarGame, error) {
+	return mockMemoryDataSource().SimilarGames(ctx, id, limit)
+}
 
-	return report, nil
+func (mockGameDataSource) Trending(ctx context.Context, opts backend.TrendingOptions) (trending backend.Trending, err error) {
+	return mockMemoryDataSource().Trending(ctx, opts)
+}
+
+func (mockGameDataSource) GameStats(ctx context.Context, id string, top int) (stats backend.GameStats, err error) {
+	return mockMemoryDataSource().GameStats(ctx, id, top)
 }
 
 func mustReq(method string, path string) *http.Request {
@@ -169,6 +282,13 @@ func mustReq(method string, path string) *http.Request {
 	return r
 }
 
+func mustAcceptReq(path string, accept string) *http.Request {
+	r := mustReq(http.MethodGet, path)
+	r.Header.Set("Accept", accept)
+
+	return r
+}
+
 func TestHandler_ServeHTTP(t *testing.T) {
 	gs := New(mockGameDataSource{}, nil)
 
@@ -205,24 +325,28 @@ func checkGame(expected backend.Game) func(t *testing.T, resp *httptest.Response
 		if err := json.Unmarshal(body, &game); err != nil {
 			t.Errorf("Error decoding response: %v", err)
 		}
+		// The id is in the URL, so isn't part of the response
+		expected.ID = ""
 		assert.Equal(t, expected, game, "Should have returned the first game")
+		assert.NotContains(t, string(body), `"id"`, "The game's id shouldn't be in the response")
 	}
 }
 
-func checkGameError(expectedErr backend.Error) func(t *testing.T, resp *httptest.ResponseRecorder) {
+func checkGameError(expected problem.Problem) func(t *testing.T, resp *httptest.ResponseRecorder) {
 	return func(t *testing.T, resp *httptest.ResponseRecorder) {
-		assert.Equal(t, http.StatusNotFound, resp.Code, "Game should not have been found")
+		assert.Equal(t, expected.Status, resp.Code, "Request should have failed")
+		assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
 
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			t.Errorf("Unable to read response: %v", err)
 		}
 
-		var errValue backend.Error
-		if err := json.Unmarshal(body, &errValue); err != nil {
+		var got problem.Problem
+		if err := json.Unmarshal(body, &got); err != nil {
 			t.Errorf("Error decoding response: %v", err)
 		}
-		assert.Equal(t, expectedErr, errValue, "Should have returned the first game")
+		assert.Equal(t, expected, got, "Should have returned the expected problem")
 	}
 }
 
@@ -245,9 +369,16 @@ func TestHandler_getGameEndpoint(t *testing.T) {
 			check: checkGame(mockGames[1]),
 		},
 		{
-			name:  "Get game not found",
-			req:   mux.SetURLVars(mustReq(http.MethodGet, "/3"), map[string]string{"id": "3"}),
-			check: checkGameError(backend.Error{Msg: "Unable to get game"}),
+			name: "Get game not found",
+			req:  mux.SetURLVars(mustReq(http.MethodGet, "/3"), map[string]string{"id": "3"}),
+			check: checkGameError(problem.Problem{
+				Type:     problem.TypePrefix + problem.CodeGameNotFound,
+				Title:    "Game not found",
+				Status:   http.StatusNotFound,
+				Detail:   "Game 3 not found",
+				Instance: "/3",
+				Code:     problem.CodeGameNotFound,
+			}),
 		},
 	}
 	for _, tt := range tests {
@@ -258,3 +389,686 @@ func TestHandler_getGameEndpoint(t *testing.T) {
 		})
 	}
 }
+
+// failingDataSource fails to get any game
+type failingDataSource struct {
+	mockGameDataSource
+}
+
+func (failingDataSource) Game(ctx context.Context, id string) (game backend.Game, err error) {
+	return game, fmt.Errorf("Connection refused")
+}
+
+func TestHandler_getGameEndpoint_dataSourceError(t *testing.T) {
+	gs := New(failingDataSource{}, nil)
+
+	resp := httptest.NewRecorder()
+	gs.getGameEndpoint(resp, mux.SetURLVars(mustReq(http.MethodGet, "/1"), map[string]string{"id": "1"}))
+
+	checkGameError(problem.Problem{
+		Type:     problem.TypePrefix + problem.CodeDataSourceError,
+		Title:    "Data source error",
+		Status:   http.StatusInternalServerError,
+		Detail:   "Connection refused",
+		Instance: "/1",
+		Code:     problem.CodeDataSourceError,
+	})(t, resp)
+}
+
+func checkReportAverages(expected ...float64) func(t *testing.T, resp *httptest.ResponseRecorder) {
+	return func(t *testing.T, resp *httptest.ResponseRecorder) {
+		assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+		var report backend.Report
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Errorf("Error decoding response: %v", err)
+		}
+
+		averages := make([]float64, 0, len(report.AverageLikesPerGame))
+		for _, stats := range report.AverageLikesPerGame {
+			averages = append(averages, stats.AverageLikes)
+		}
+		assert.Equal(t, expected, averages, "Averages should have been rounded")
+	}
+}
+
+func TestHandler_reportEndpoint(t *testing.T) {
+	gs := New(mockGameDataSource{}, nil)
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "Exact by default",
+			req:   mustReq(http.MethodGet, "/report"),
+			check: checkReportAverages(9, 3, 1.3333333333333333),
+		},
+		{
+			name:  "Ceil",
+			req:   mustReq(http.MethodGet, "/report?rounding=ceil"),
+			check: checkReportAverages(9, 3, 2),
+		},
+		{
+			name:  "Floor",
+			req:   mustReq(http.MethodGet, "/report?rounding=floor"),
+			check: checkReportAverages(9, 3, 1),
+		},
+		{
+			name:  "Nearest",
+			req:   mustReq(http.MethodGet, "/report?rounding=nearest"),
+			check: checkReportAverages(9, 3, 1),
+		},
+		{
+			name: "Stream as NDJSON",
+			req:  mustAcceptReq("/report?rounding=ceil", "application/x-ndjson"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+				assert.Equal(t, "application/x-ndjson", resp.Header().Get("Content-Type"))
+
+				dec := json.NewDecoder(resp.Body)
+				averages := make([]float64, 0)
+				for range mockReport.AverageLikesPerGame {
+					var stats backend.GameAverageLikes
+					if err := dec.Decode(&stats); err != nil {
+						t.Fatalf("Error decoding row: %v", err)
+					}
+					averages = append(averages, stats.AverageLikes)
+				}
+				assert.Equal(t, []float64{9, 3, 2}, averages)
+
+				var summary reportSummary
+				if err := dec.Decode(&summary); err != nil {
+					t.Fatalf("Error decoding summary: %v", err)
+				}
+				assert.Equal(t, reportSummary{
+					UserWithMostComments: mockReport.UserWithMostComments,
+					HighestRatedGame:     mockReport.HighestRatedGame,
+				}, summary)
+				assert.False(t, dec.More(), "Summary should be the last line")
+			},
+		},
+		{
+			name: "Selected sections",
+			req:  mustReq(http.MethodGet, "/report?sections=highest_rated_game,average_likes_per_game&rounding=ceil"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var sections struct {
+					HighestRatedGame    *string                    `json:"highest_rated_game"`
+					UserWithMostComment *string                    `json:"user_with_most_comments"`
+					AverageLikesPerGame []backend.GameAverageLikes `json:"average_likes_per_game"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&sections); err != nil {
+					t.Fatalf("Error decoding response: %v", err)
+				}
+
+				assert.Equal(t, "Solitary Voyage", *sections.HighestRatedGame)
+				assert.Nil(t, sections.UserWithMostComment, "Only the requested sections should be computed")
+				assert.Len(t, sections.AverageLikesPerGame, 2)
+			},
+		},
+		{
+			name: "Unknown section",
+			req:  mustReq(http.MethodGet, "/report?sections=highest_rated_game,vibes"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Section should have been rejected")
+			},
+		},
+		{
+			name: "Invalid rounding",
+			req:  mustReq(http.MethodGet, "/report?rounding=sideways"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Rounding should have been rejected")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			gs.reportEndpoint(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestHandler_timeSeriesEndpoint(t *testing.T) {
+	gs := New(mockGameDataSource{}, nil)
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Monthly buckets",
+			req:  mustReq(http.MethodGet, "/report/timeseries?interval=month"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var series backend.TimeSeries
+				if err := json.NewDecoder(resp.Body).Decode(&series); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+
+				assert.Equal(t, backend.IntervalMonth, series.Interval)
+				assert.Equal(t, []backend.TimeBucket{
+					{Start: createTime("1991-04-01"), Comments: 1, Likes: 1},
+					{Start: createTime("2001-08-01"), Comments: 1, Likes: 9},
+					{Start: createTime("2004-03-01"), Comments: 1, Likes: 5},
+				}, series.Overall)
+				assert.Len(t, series.Games, 2, "Should have a series for each game")
+			},
+		},
+		{
+			name: "Invalid interval",
+			req:  mustReq(http.MethodGet, "/report/timeseries?interval=fortnight"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Interval should have been rejected")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			gs.ServeHTTP(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestHandler_similarGamesEndpoint(t *testing.T) {
+	gs := New(mockGameDataSource{}, nil)
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Similar by commenters",
+			req:  mustReq(http.MethodGet, "/2/similar"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var similar []backend.SimilarGame
+				if err := json.NewDecoder(resp.Body).Decode(&similar); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+
+				if assert.Len(t, similar, 1, "Dummy shares a commenter") {
+					assert.Equal(t, "1", similar[0].ID)
+					assert.Equal(t, []backend.SimilarityReason{
+						{Kind: backend.ReasonCommenters, Detail: "1 commenter in common", Score: 0.15},
+					}, similar[0].Reasons)
+				}
+			},
+		},
+		{
+			name: "Unknown game",
+			req:  mustReq(http.MethodGet, "/3/similar"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, resp.Code, "Game should not have been found")
+			},
+		},
+		{
+			name: "Invalid limit",
+			req:  mustReq(http.MethodGet, "/1/similar?limit=lots"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Limit should have been rejected")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			gs.ServeHTTP(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestHandler_trendingEndpoint(t *testing.T) {
+	gs := New(mockGameDataSource{}, nil)
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Long window",
+			req:  mustReq(http.MethodGet, "/trending?window=100000d&half_life=1w"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var trending backend.Trending
+				if err := json.NewDecoder(resp.Body).Decode(&trending); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+
+				if assert.Len(t, trending.Games, 2, "Both games have comments in the window") {
+					assert.Equal(t, "Dummy", trending.Games[0].Title, "Most recent comments should rank first")
+					assert.Equal(t, 2, trending.Games[0].Comments)
+					assert.Equal(t, 6, trending.Games[0].Likes)
+				}
+			},
+		},
+		{
+			name: "Default window",
+			req:  mustReq(http.MethodGet, "/trending"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var trending backend.Trending
+				if err := json.NewDecoder(resp.Body).Decode(&trending); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+
+				assert.Empty(t, trending.Games, "No comments were made in the last week")
+				assert.Equal(t, 7*24*time.Hour, trending.To.Sub(trending.From))
+			},
+		},
+		{
+			name: "Invalid half life",
+			req:  mustReq(http.MethodGet, "/trending?half_life=0d"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Half life should have been rejected")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			gs.ServeHTTP(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestHandler_gameStatsEndpoint(t *testing.T) {
+	gs := New(mockGameDataSource{}, nil)
+
+	first := createTime("1991-04-12")
+	last := createTime("2004-03-19")
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Stats for game 1",
+			req:  mustReq(http.MethodGet, "/1/stats?top=1"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var stats backend.GameStats
+				if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+
+				assert.Equal(t, backend.GameStats{
+					GameAverageLikes: backend.GameAverageLikes{
+						Title:        "Dummy",
+						Comments:     2,
+						TotalLikes:   6,
+						AverageLikes: 3,
+						MedianLikes:  3,
+						MinLikes:     1,
+						MaxLikes:     5,
+						StdDevLikes:  2,
+					},
+					UniqueCommenters: 2,
+					FirstComment:     &first,
+					LastComment:      &last,
+					TopCommenters: []backend.CommenterStats{
+						{User: "Jacqueline Dodson", Comments: 1, Likes: 5},
+					},
+				}, stats)
+			},
+		},
+		{
+			name: "Game not found",
+			req:  mustReq(http.MethodGet, "/3/stats"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, resp.Code, "Game should not have been found")
+			},
+		},
+		{
+			name: "Invalid top",
+			req:  mustReq(http.MethodGet, "/1/stats?top=lots"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Top should have been rejected")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			gs.ServeHTTP(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestHandler_reportHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reports")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := backend.NewFileReportStore(dir)
+	if err != nil {
+		t.Fatalf("Unable to create store: %v", err)
+	}
+
+	older := backend.ReportSnapshot{
+		TakenAt: time.Date(2020, 5, 18, 9, 0, 0, 0, time.UTC),
+		Report:  backend.Report{HighestRatedGame: "Dummy"},
+	}
+	newer := backend.ReportSnapshot{
+		TakenAt: time.Date(2020, 5, 25, 9, 0, 0, 0, time.UTC),
+		Report:  mockReport,
+	}
+	for _, snapshot := range []backend.ReportSnapshot{older, newer} {
+		if err := store.SaveReport(context.Background(), snapshot); err != nil {
+			t.Fatalf("Unable to save snapshot: %v", err)
+		}
+	}
+
+	gs := New(backend.WithReportStore(mockGameDataSource{}, store), nil)
+	noStore := New(mockGameDataSource{}, nil)
+
+	tests := []struct {
+		name  string
+		gs    *Handler
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name: "History",
+			gs:   gs,
+			req:  mustReq(http.MethodGet, "/report/history?from=2020-05-20"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var history []backend.ReportSnapshot
+				if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+				assert.Equal(t, []backend.ReportSnapshot{newer}, history)
+			},
+		},
+		{
+			name: "Diff",
+			gs:   gs,
+			req:  mustReq(http.MethodGet, "/report/diff?from=2020-05-18&to=2020-05-25"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var diff backend.ReportDiff
+				if err := json.NewDecoder(resp.Body).Decode(&diff); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+				assert.Equal(t, backend.StringChange{From: "Dummy", To: "Solitary Voyage", Changed: true}, diff.HighestRatedGame)
+				assert.Len(t, diff.AverageLikesPerGame, 3)
+			},
+		},
+		{
+			name: "Diff before first snapshot",
+			gs:   gs,
+			req:  mustReq(http.MethodGet, "/report/diff?from=2020-05-17"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, resp.Code, "Snapshot should not have been found")
+			},
+		},
+		{
+			name: "Diff without from",
+			gs:   gs,
+			req:  mustReq(http.MethodGet, "/report/diff"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "From should be required")
+			},
+		},
+		{
+			name: "No report store",
+			gs:   noStore,
+			req:  mustReq(http.MethodGet, "/report/history"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotImplemented, resp.Code, "History should not be available")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			tt.gs.ServeHTTP(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestHandler_export(t *testing.T) {
+	gs := New(mockGameDataSource{}, nil)
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Game as CSV",
+			req:  mustAcceptReq("/2", "text/csv"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+				assert.Equal(t, "text/csv", resp.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename="game-2.csv"`, resp.Header().Get("Content-Disposition"))
+				assert.Equal(t, "title,description,by,platform,age_rating,likes,comment_user,comment_message,comment_date_created,comment_like\n"+
+					"Solitary Voyage,Decsription goes here,Jimmie Bassett,PC; XBOX,6+,99,Jacqueline Dodson,Mauris blandit orci at magna venenatis euismod.,2001-08-16,9\n",
+					resp.Body.String())
+			},
+		},
+		{
+			name: "Report columns as CSV",
+			req:  mustReq(http.MethodGet, "/report?format=csv&columns=title,average_likes&rounding=floor"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+				assert.Equal(t, "title,average_likes\nSolitary Voyage,9\nDummy,3\nRounding,1\n", resp.Body.String())
+			},
+		},
+		{
+			name: "Report as XLSX",
+			req:  mustAcceptReq("/report", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+				assert.Equal(t, `attachment; filename="report.xlsx"`, resp.Header().Get("Content-Disposition"))
+				assert.Equal(t, "PK", resp.Body.String()[:2], "Workbook should be a zip archive")
+			},
+		},
+		{
+			name: "Unknown column",
+			req:  mustReq(http.MethodGet, "/report?format=csv&columns=title,colour"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Column should have been rejected")
+			},
+		},
+		{
+			name: "Unknown format",
+			req:  mustReq(http.MethodGet, "/1?format=pdf"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Format should have been rejected")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			gs.ServeHTTP(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestHandler_maxAge(t *testing.T) {
+	gs := New(mockMemoryDataSource(), nil)
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "Suitable game",
+			req:   mustReq(http.MethodGet, "/2?max_age=10"),
+			check: checkGame(mockGames[1]),
+		},
+		{
+			name: "Hidden game",
+			req:  mustReq(http.MethodGet, "/1?max_age=10"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, resp.Code, "Game above the age limit should be hidden")
+			},
+		},
+		{
+			name: "Report without hidden games",
+			req:  mustReq(http.MethodGet, "/report?max_age=10"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var report backend.Report
+				if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+
+				assert.Equal(t, "Solitary Voyage", report.HighestRatedGame)
+				assert.Len(t, report.AverageLikesPerGame, 1, "Only the suitable game should be reported")
+			},
+		},
+		{
+			name: "Age rating section",
+			req:  mustReq(http.MethodGet, "/report?sections=age_ratings"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code, "Request should have succeeded")
+
+				var sections struct {
+					AgeRatings backend.AgeRatingReport `json:"age_ratings"`
+				}
+				if err := json.NewDecoder(resp.Body).Decode(&sections); err != nil {
+					t.Errorf("Error decoding response: %v", err)
+				}
+
+				assert.Equal(t, []backend.AgeRatingBand{
+					{Band: "7+", MinAge: 7, Games: 1, GameLikes: 99, Comments: 1, CommentLikes: 9},
+					{Band: "18+", MinAge: 18, Games: 1, GameLikes: 42, Comments: 2, CommentLikes: 6},
+				}, sections.AgeRatings.Bands)
+			},
+		},
+		{
+			name: "Invalid max age",
+			req:  mustReq(http.MethodGet, "/report?max_age=-1"),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, resp.Code, "Negative ages should be rejected")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			gs.ServeHTTP(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestHandler_conditional(t *testing.T) {
+	gs := New(mockMemoryDataSource(), nil)
+	gs.SetCacheControl("max-age=60", "")
+
+	fetch := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := mustReq(http.MethodGet, path)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		resp := httptest.NewRecorder()
+		gs.ServeHTTP(resp, req)
+		return resp
+	}
+
+	game := fetch("/1", nil)
+	gameETag := game.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, gameETag, "Games should have a strong ETag")
+	assert.Equal(t, "Fri, 19 Mar 2004 00:00:00 GMT", game.Header().Get("Last-Modified"), "Games should be modified when last commented on")
+	assert.Equal(t, "max-age=60", game.Header().Get("Cache-Control"))
+
+	report := fetch("/report", nil)
+	reportETag := report.Header().Get("ETag")
+	assert.NotEmpty(t, reportETag, "Reports should have an ETag")
+	assert.Equal(t, "Fri, 19 Mar 2004 00:00:00 GMT", report.Header().Get("Last-Modified"), "Reports should be modified when any game is commented on")
+	assert.Empty(t, report.Header().Get("Cache-Control"), "No Cache-Control should be sent when disabled")
+	assert.NotEqual(t, reportETag, fetch("/report?rounding=2", nil).Header().Get("ETag"), "Different representations should have different ETags")
+
+	tests := []struct {
+		name       string
+		path       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{
+			name:       "Matching ETag",
+			path:       "/1",
+			headers:    map[string]string{"If-None-Match": gameETag},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "Matching one of several ETags",
+			path:       "/report",
+			headers:    map[string]string{"If-None-Match": `"stale", W/` + reportETag},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "Other game's ETag",
+			path:       "/2",
+			headers:    map[string]string{"If-None-Match": gameETag},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "ETag checked before date",
+			path:       "/1",
+			headers:    map[string]string{"If-None-Match": `"stale"`, "If-Modified-Since": "Sat, 01 Jan 2005 00:00:00 GMT"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Not modified since",
+			path:       "/1",
+			headers:    map[string]string{"If-Modified-Since": "Fri, 19 Mar 2004 00:00:00 GMT"},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			name:       "Modified since",
+			path:       "/report",
+			headers:    map[string]string{"If-Modified-Since": "Thu, 18 Mar 2004 23:59:59 GMT"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Invalid date",
+			path:       "/1",
+			headers:    map[string]string{"If-Modified-Since": "yesterday"},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := fetch(tt.path, tt.headers)
+			assert.Equal(t, tt.wantStatus, resp.Code)
+
+			if tt.wantStatus == http.StatusNotModified {
+				assert.Empty(t, resp.Body.String(), "Not modified responses should have no body")
+				assert.NotEmpty(t, resp.Header().Get("ETag"), "Not modified responses should still have the ETag")
+			}
+		})
+	}
+}
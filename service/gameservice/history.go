@@ -0,0 +1,151 @@
+package gameservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+)
+
+// The formats accepted for the from and to query parameters
+const (
+	dateFormat     = "2006-01-02"
+	dateTimeFormat = time.RFC3339
+)
+
+// reportStore returns the report store for the data source, if it has one
+func (gs *Handler) reportStore() (backend.ReportStore, bool) {
+	store, ok := gs.ds.(backend.ReportStore)
+	return store, ok
+}
+
+// reportHistoryEndpoint is the handler for the /report/history endpoint. It
+// returns the snapshots taken between the optional from and to query
+// parameters.
+func (gs *Handler) reportHistoryEndpoint(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debugf("Get report history")
+
+	w.Header().Set("Content-Type", "application/json")
+	store, ok := gs.reportStore()
+	if !ok {
+		noReportStoreError(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := parseTimeParam("from", query.Get("from"), time.Time{}, false)
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	to, err := parseTimeParam("to", query.Get("to"), time.Now(), true)
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	history, err := store.ReportHistory(ctx, from, to)
+	if err != nil {
+		reportError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(history)
+}
+
+// reportDiffEndpoint is the handler for the /report/diff endpoint. It compares
+// the latest snapshots taken at or before the from and to query parameters. to
+// defaults to now.
+func (gs *Handler) reportDiffEndpoint(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debugf("Get report diff")
+
+	w.Header().Set("Content-Type", "application/json")
+	store, ok := gs.reportStore()
+	if !ok {
+		noReportStoreError(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("from") == "" {
+		badRequestError(w, r, fmt.Errorf("Invalid request. Require parameter from."))
+		return
+	}
+
+	from, err := parseTimeParam("from", query.Get("from"), time.Time{}, true)
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	to, err := parseTimeParam("to", query.Get("to"), time.Now(), true)
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	fromSnapshot, err := store.ReportAt(ctx, from)
+	if err != nil {
+		snapshotError(w, r, err)
+		return
+	}
+
+	toSnapshot, err := store.ReportAt(ctx, to)
+	if err != nil {
+		snapshotError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(backend.DiffReports(fromSnapshot, toSnapshot))
+}
+
+// parseTimeParam parses a date or RFC3339 time given in the query parameter
+// name. def is returned when value is empty. When endOfDay is set a date
+// refers to the end of that day rather than its start, so that snapshots
+// taken during the day are included.
+func parseTimeParam(name, value string, def time.Time, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return def, nil
+	}
+
+	if t, err := time.Parse(dateTimeFormat, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(dateFormat, value)
+	if err != nil {
+		return t, fmt.Errorf("Invalid %s %q, expected a date (%s) or time (%s)",
+			name, value, dateFormat, dateTimeFormat)
+	}
+
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	return t, nil
+}
+
+func noReportStoreError(w http.ResponseWriter, r *http.Request) {
+	problem.Error(w, r, http.StatusNotImplemented, problem.CodeHistoryUnavailable,
+		"Report history is not available for this data source")
+}
+
+func snapshotError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == backend.ErrSnapshotNotFound {
+		problem.Error(w, r, http.StatusNotFound, problem.CodeSnapshotNotFound, err.Error())
+		return
+	}
+
+	reportError(w, r, err)
+}
@@ -0,0 +1,340 @@
+package gameservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/gorilla/mux"
+)
+
+// jobTTL is how long a finished report job is kept so that its result can be
+// fetched
+const jobTTL = time.Hour
+
+// The limits on report jobs unless changed with Handler.SetJobLimits. Each job
+// runs every report aggregation over the whole collection, so only a few are
+// allowed at once.
+const (
+	DefaultMaxReportJobs    = 4
+	DefaultReportJobTimeout = 10 * time.Minute
+)
+
+// errTooManyJobs is returned when a job is started while the maximum number
+// are running
+var errTooManyJobs = errors.New("Too many report jobs running")
+
+// JobStatus is the state of a report job
+type JobStatus string
+
+// The states a report job can be in
+const (
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// ReportJob describes a report being created in the background. Progress is
+// the fraction of the report completed, between 0 and 1.
+type ReportJob struct {
+	ID         string     `json:"id"`
+	Status     JobStatus  `json:"status"`
+	Progress   float64    `json:"progress"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+type reportJob struct {
+	ReportJob
+	report backend.Report
+	cancel context.CancelFunc
+}
+
+// reportJobs runs reports in the background and keeps track of their progress
+type reportJobs struct {
+	mu   sync.Mutex
+	jobs map[string]*reportJob
+	// max is the number of jobs which can run at once and timeout how long
+	// each can run for
+	max     int
+	timeout time.Duration
+}
+
+func newReportJobs() *reportJobs {
+	return &reportJobs{
+		jobs:    make(map[string]*reportJob),
+		max:     DefaultMaxReportJobs,
+		timeout: DefaultReportJobTimeout,
+	}
+}
+
+// running counts the jobs which are still running. jobs.mu must be held.
+func (jobs *reportJobs) running() int {
+	running := 0
+	for _, job := range jobs.jobs {
+		if job.Status == JobRunning {
+			running++
+		}
+	}
+
+	return running
+}
+
+// start begins creating a report from ds in the background. The report is
+// limited by the age set on parent, see backend.WithMaxAge, and logged with
+// parent's logger. Jobs outlive the request starting them so nothing else is
+// taken from parent. Jobs fail once they have run for the jobs' timeout, and
+// errTooManyJobs is returned if the maximum number are already running.
+func (jobs *reportJobs) start(parent context.Context, ds backend.GameDataSource) (ReportJob, error) {
+	id, err := newJobID()
+	if err != nil {
+		return ReportJob{}, err
+	}
+
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	jobs.expire()
+	if jobs.running() >= jobs.max {
+		return ReportJob{}, errTooManyJobs
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobs.timeout)
+	if age, ok := backend.MaxAge(parent); ok {
+		ctx = backend.WithMaxAge(ctx, age)
+	}
+	logger := logging.FromContext(parent).WithField("job_id", id)
+	ctx = logging.WithLogger(ctx, logger)
+	job := &reportJob{
+		ReportJob: ReportJob{
+			ID:        id,
+			Status:    JobRunning,
+			CreatedAt: time.Now().UTC(),
+		},
+		cancel: cancel,
+	}
+
+	jobs.jobs[id] = job
+
+	ctx = backend.WithProgress(ctx, func(done, total int) {
+		if total <= 0 {
+			return
+		}
+
+		jobs.mu.Lock()
+		job.Progress = float64(done) / float64(total)
+		jobs.mu.Unlock()
+	})
+
+	go func() {
+		defer cancel()
+
+		logger.Debugf("Report job started")
+		report, err := ds.Report(ctx)
+		status := jobs.finish(job, report, err)
+		logger.Debugf("Report job finished: %s", status)
+	}()
+
+	return job.ReportJob, nil
+}
+
+// finish records the outcome of a job, returning its final status
+func (jobs *reportJobs) finish(job *reportJob, report backend.Report, err error) JobStatus {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	// Cancelled jobs were finished when they were cancelled
+	if job.Status == JobCancelled {
+		return job.Status
+	}
+
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+
+	switch {
+	case err != nil:
+		job.Status = JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = JobSucceeded
+		job.Progress = 1
+		job.report = report
+	}
+
+	return job.Status
+}
+
+// get returns the job with the given id and its report, which is only set
+// once the job has succeeded
+func (jobs *reportJobs) get(id string) (ReportJob, backend.Report, bool) {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	jobs.expire()
+	job, ok := jobs.jobs[id]
+	if !ok {
+		return ReportJob{}, backend.Report{}, false
+	}
+
+	return job.ReportJob, job.report, true
+}
+
+// cancel stops the job with the given id if it is still running
+func (jobs *reportJobs) cancel(id string) (ReportJob, bool) {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	job, ok := jobs.jobs[id]
+	if !ok {
+		return ReportJob{}, false
+	}
+
+	if job.Status == JobRunning {
+		now := time.Now().UTC()
+		job.Status = JobCancelled
+		job.FinishedAt = &now
+		job.cancel()
+	}
+
+	return job.ReportJob, true
+}
+
+// cancelAll stops every job which is still running
+func (jobs *reportJobs) cancelAll() {
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, job := range jobs.jobs {
+		if job.Status == JobRunning {
+			job.Status = JobCancelled
+			job.FinishedAt = &now
+			job.cancel()
+		}
+	}
+}
+
+// expire removes jobs which finished more than jobTTL ago. jobs.mu must be
+// held.
+func (jobs *reportJobs) expire() {
+	cutoff := time.Now().Add(-jobTTL)
+	for id, job := range jobs.jobs {
+		if job.FinishedAt != nil && job.FinishedAt.Before(cutoff) {
+			delete(jobs.jobs, id)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("Unable to create job id: %v", err)
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// tooManyJobsRetry is how long clients are told to wait when too many jobs
+// are running
+const tooManyJobsRetry = 30 * time.Second
+
+// startReportJobEndpoint is the handler for POST /report/jobs. It starts a
+// report in the background and responds with the job, which is located at
+// the URL in the Location header. While the maximum number of jobs are
+// running it responds with 503 Service Unavailable.
+func (gs *Handler) startReportJobEndpoint(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debugf("Start report job")
+
+	w.Header().Set("Content-Type", "application/json")
+	job, err := gs.jobs.start(r.Context(), gs.ds)
+	if err == errTooManyJobs {
+		w.Header().Set("Retry-After", strconv.Itoa(int(tooManyJobsRetry.Seconds())))
+		problem.Error(w, r, http.StatusServiceUnavailable, problem.CodeTooManyJobs,
+			"The most report jobs allowed are already running, try again later")
+		return
+	}
+	if err != nil {
+		reportError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", r.URL.Path+"/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// reportJobEndpoint is the handler for GET /report/jobs/<job_id>, which
+// responds with the status and progress of the job
+func (gs *Handler) reportJobEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	job, _, ok := gs.jobs.get(id)
+	if !ok {
+		jobNotFoundError(w, r, id)
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+// reportJobResultEndpoint is the handler for GET /report/jobs/<job_id>/result.
+// It responds with the report once the job has succeeded. The rounding query
+// parameter is handled as for the report endpoint.
+func (gs *Handler) reportJobResultEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	rounding, err := backend.ParseRounding(r.URL.Query().Get("rounding"))
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	job, report, ok := gs.jobs.get(id)
+	if !ok {
+		jobNotFoundError(w, r, id)
+		return
+	}
+
+	if job.Status != JobSucceeded {
+		problem.Error(w, r, http.StatusConflict, problem.CodeJobNotFinished,
+			fmt.Sprintf("Report job %s has not succeeded, it is %s", id, job.Status))
+		return
+	}
+
+	json.NewEncoder(w).Encode(report.Rounded(rounding))
+}
+
+// cancelReportJobEndpoint is the handler for DELETE /report/jobs/<job_id>. It
+// cancels the job if it is still running and responds with its status.
+func (gs *Handler) cancelReportJobEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id := mux.Vars(r)["id"]
+
+	logging.FromContext(r.Context()).Debugf("Cancel report job %s", id)
+
+	job, ok := gs.jobs.cancel(id)
+	if !ok {
+		jobNotFoundError(w, r, id)
+		return
+	}
+
+	json.NewEncoder(w).Encode(job)
+}
+
+func jobNotFoundError(w http.ResponseWriter, r *http.Request, id string) {
+	problem.Error(w, r, http.StatusNotFound, problem.CodeJobNotFound, fmt.Sprintf("Report job %s not found", id))
+}
@@ -0,0 +1,146 @@
+package gameservice
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/stretchr/testify/assert"
+)
+
+// blockingDataSource creates reports which never finish until cancelled
+type blockingDataSource struct {
+	mockGameDataSource
+}
+
+func (blockingDataSource) Report(ctx context.Context) (report backend.Report, err error) {
+	<-ctx.Done()
+	return report, ctx.Err()
+}
+
+func serve(gs *Handler, req *http.Request) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	gs.ServeHTTP(resp, req)
+	return resp
+}
+
+func decodeJob(t *testing.T, resp *httptest.ResponseRecorder) ReportJob {
+	var job ReportJob
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		t.Fatalf("Error decoding job: %v", err)
+	}
+
+	return job
+}
+
+// waitForJob polls the job until it is no longer running
+func waitForJob(t *testing.T, gs *Handler, id string) ReportJob {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job := decodeJob(t, serve(gs, mustReq(http.MethodGet, "/report/jobs/"+id)))
+		if job.Status != JobRunning {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("Job %s did not finish", id)
+	return ReportJob{}
+}
+
+func TestHandler_reportJobSucceeds(t *testing.T) {
+	gs := New(mockGameDataSource{}, nil)
+
+	resp := serve(gs, mustReq(http.MethodPost, "/report/jobs"))
+	assert.Equal(t, http.StatusAccepted, resp.Code, "Job should have been accepted")
+
+	job := decodeJob(t, resp)
+	assert.Equal(t, "/report/jobs/"+job.ID, resp.Header().Get("Location"))
+
+	job = waitForJob(t, gs, job.ID)
+	assert.Equal(t, JobSucceeded, job.Status)
+	assert.Equal(t, 1.0, job.Progress)
+
+	resp = serve(gs, mustReq(http.MethodGet, "/report/jobs/"+job.ID+"/result"))
+	assert.Equal(t, http.StatusOK, resp.Code, "Result should be available")
+
+	var report backend.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Errorf("Error decoding report: %v", err)
+	}
+	assert.Equal(t, mockReport, report)
+}
+
+func TestHandler_reportJobCancelled(t *testing.T) {
+	gs := New(blockingDataSource{}, nil)
+
+	job := decodeJob(t, serve(gs, mustReq(http.MethodPost, "/report/jobs")))
+	assert.Equal(t, JobRunning, job.Status)
+
+	resp := serve(gs, mustReq(http.MethodGet, "/report/jobs/"+job.ID+"/result"))
+	assert.Equal(t, http.StatusConflict, resp.Code, "Result should not be available yet")
+
+	resp = serve(gs, mustReq(http.MethodDelete, "/report/jobs/"+job.ID))
+	assert.Equal(t, http.StatusOK, resp.Code, "Job should have been cancelled")
+	assert.Equal(t, JobCancelled, decodeJob(t, resp).Status)
+
+	job = waitForJob(t, gs, job.ID)
+	assert.Equal(t, JobCancelled, job.Status)
+	assert.NotNil(t, job.FinishedAt)
+}
+
+func TestHandler_reportJobNotFound(t *testing.T) {
+	gs := New(mockGameDataSource{}, nil)
+
+	resp := serve(gs, mustReq(http.MethodGet, "/report/jobs/abc123"))
+	assert.Equal(t, http.StatusNotFound, resp.Code, "Job should not have been found")
+}
+
+func TestHandler_CancelJobs(t *testing.T) {
+	gs := New(blockingDataSource{}, nil)
+
+	first := decodeJob(t, serve(gs, mustReq(http.MethodPost, "/report/jobs")))
+	second := decodeJob(t, serve(gs, mustReq(http.MethodPost, "/report/jobs")))
+
+	gs.CancelJobs()
+
+	for _, id := range []string{first.ID, second.ID} {
+		job := waitForJob(t, gs, id)
+		assert.Equal(t, JobCancelled, job.Status)
+		assert.NotNil(t, job.FinishedAt)
+	}
+}
+
+func TestHandler_reportJobLimits(t *testing.T) {
+	gs := New(blockingDataSource{}, nil)
+	gs.SetJobLimits(2, time.Hour)
+	defer gs.CancelJobs()
+
+	first := decodeJob(t, serve(gs, mustReq(http.MethodPost, "/report/jobs")))
+	serve(gs, mustReq(http.MethodPost, "/report/jobs"))
+
+	resp := serve(gs, mustReq(http.MethodPost, "/report/jobs"))
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code, "Jobs over the limit should be rejected")
+	assert.NotEmpty(t, resp.Header().Get("Retry-After"))
+	assert.Contains(t, resp.Body.String(), problem.CodeTooManyJobs)
+
+	serve(gs, mustReq(http.MethodDelete, "/report/jobs/"+first.ID))
+	resp = serve(gs, mustReq(http.MethodPost, "/report/jobs"))
+	assert.Equal(t, http.StatusAccepted, resp.Code, "Cancelled jobs shouldn't count towards the limit")
+}
+
+func TestHandler_reportJobTimeout(t *testing.T) {
+	gs := New(blockingDataSource{}, nil)
+	gs.SetJobLimits(DefaultMaxReportJobs, 10*time.Millisecond)
+
+	job := decodeJob(t, serve(gs, mustReq(http.MethodPost, "/report/jobs")))
+
+	job = waitForJob(t, gs, job.ID)
+	assert.Equal(t, JobFailed, job.Status, "Jobs should fail once they time out")
+	assert.Contains(t, job.Error, context.DeadlineExceeded.Error())
+}
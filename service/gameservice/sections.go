@@ -0,0 +1,70 @@
+package gameservice
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/logging"
+)
+
+// parseSections splits the comma separated sections query parameter, checking
+// each section is registered
+func parseSections(param string) ([]string, error) {
+	registered := make(map[string]bool)
+	for _, name := range backend.ReportSectionNames() {
+		registered[name] = true
+	}
+
+	names := make([]string, 0)
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if !registered[name] {
+			return nil, fmt.Errorf("Unknown report section %q, expected one of %s",
+				name, strings.Join(backend.ReportSectionNames(), ", "))
+		}
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("Invalid request. Parameter sections lists no sections.")
+	}
+
+	return names, nil
+}
+
+// reportSections responds with only the requested sections of the report,
+// keyed by section name
+func (gs *Handler) reportSections(w http.ResponseWriter, r *http.Request, names []string, rounding backend.Rounding) {
+	logging.FromContext(r.Context()).Debugf("Get report sections %v", names)
+
+	ctx, cancel := gs.requestContext(r)
+	defer cancel()
+
+	results, err := gs.ds.ReportSections(ctx, names)
+	if err != nil {
+		reportError(w, r, err)
+		return
+	}
+
+	for name, result := range results {
+		if games, ok := result.([]backend.GameAverageLikes); ok {
+			results[name] = backend.Report{AverageLikesPerGame: games}.Rounded(rounding).AverageLikesPerGame
+		}
+	}
+
+	json.NewEncoder(w).Encode(results)
+}
+
+// reportSectionsEndpoint is the handler for the /report/sections endpoint,
+// which lists the sections that can be requested from /report
+func (gs *Handler) reportSectionsEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(backend.ReportSectionNames())
+}
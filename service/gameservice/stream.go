@@ -0,0 +1,96 @@
+package gameservice
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+)
+
+// ndjsonContentType is the media type of newline delimited JSON
+const ndjsonContentType = "application/x-ndjson"
+
+// ndjsonFlushRows is how many rows of a streamed report are written between
+// flushes
+const ndjsonFlushRows = 100
+
+// reportSummary is the final line of a streamed report
+type reportSummary struct {
+	UserWithMostComments string `json:"user_with_most_comments"`
+	HighestRatedGame     string `json:"highest_rated_game"`
+}
+
+// acceptedMediaTypes lists the media types in the Accept header of r, in the
+// order they are given
+func acceptedMediaTypes(r *http.Request) []string {
+	mediaTypes := make([]string, 0)
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		accepted = strings.TrimSpace(strings.SplitN(accepted, ";", 2)[0])
+		if accepted != "" {
+			mediaTypes = append(mediaTypes, accepted)
+		}
+	}
+
+	return mediaTypes
+}
+
+// accepts reports whether the Accept header of r lists mediaType
+func accepts(r *http.Request, mediaType string) bool {
+	for _, accepted := range acceptedMediaTypes(r) {
+		if strings.EqualFold(accepted, mediaType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// streamReport writes the report as newline delimited JSON. Each game is
+// written on its own line as it is produced by the data source, most liked
+// first as in a JSON report, followed by a final line holding the report
+// summary. If the data source fails part way through the last line is an
+// error instead.
+//
+// Streams aren't limited by the request timeout as they are meant for
+// catalogues too large to report on in one go, they end when the client goes
+// away.
+func (gs *Handler) streamReport(w http.ResponseWriter, r *http.Request, rounding backend.Rounding) {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	flusher, canFlush := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	rows := 0
+	summary, err := gs.ds.StreamReport(r.Context(), func(stats backend.GameAverageLikes) error {
+		if err := enc.Encode(stats.Rounded(rounding)); err != nil {
+			return err
+		}
+
+		rows++
+		if canFlush && rows%ndjsonFlushRows == 0 {
+			flusher.Flush()
+		}
+
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Warnf("Error streaming report after %d rows: %v", rows, err)
+		if rows == 0 {
+			reportError(w, r, err)
+			return
+		}
+
+		// The status has already been sent, so the problem is the last line
+		p := problem.New(http.StatusInternalServerError, problem.CodeDataSourceError, err.Error())
+		p.Instance = r.URL.RequestURI()
+		enc.Encode(p)
+		return
+	}
+
+	enc.Encode(reportSummary{
+		UserWithMostComments: summary.UserWithMostComments,
+		HighestRatedGame:     summary.HighestRatedGame,
+	})
+}
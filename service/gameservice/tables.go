@@ -0,0 +1,126 @@
+package gameservice
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/export"
+)
+
+// exportFormat works out whether r asks for a spreadsheet rather than JSON.
+// The format query parameter takes precedence over the Accept header so that
+// downloads can be linked to directly.
+func exportFormat(r *http.Request) (format export.Format, ok bool, err error) {
+	if param := r.URL.Query().Get("format"); param != "" && param != "json" {
+		format, err = export.ParseFormat(param)
+		return format, err == nil, err
+	}
+
+	for _, mediaType := range acceptedMediaTypes(r) {
+		if format, ok := export.FormatForMediaType(mediaType); ok {
+			return format, true, nil
+		}
+	}
+
+	return format, false, nil
+}
+
+// writeTable responds with the table in the given format as a download named
+// name. Only the columns listed in the columns query parameter are included,
+// or every column if there is no such parameter.
+func writeTable(w http.ResponseWriter, r *http.Request, format export.Format, name string, table export.Table) {
+	table, err := table.Select(splitList(r.URL.Query().Get("columns")))
+	if err != nil {
+		badRequestError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	if err := format.Write(w, name, table); err != nil {
+		logging.FromContext(r.Context()).Warnf("Error writing %s export %s: %v", format, name, err)
+	}
+}
+
+// splitList splits a comma separated query parameter, ignoring empty items
+func splitList(param string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(param, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// gameTable flattens a game into one row per comment, repeating the game's
+// details on each. A game without comments has a single row with empty
+// comment columns.
+func gameTable(game backend.Game) export.Table {
+	table := export.Table{
+		Columns: []string{
+			"title", "description", "by", "platform", "age_rating", "likes",
+			"comment_user", "comment_message", "comment_date_created", "comment_like",
+		},
+	}
+
+	gameColumns := []interface{}{
+		game.Title,
+		game.Description,
+		game.By,
+		strings.Join(game.Platform, "; "),
+		game.AgeRating.String(),
+		game.Likes,
+	}
+
+	if len(game.Comments) == 0 {
+		row := append(append([]interface{}{}, gameColumns...), "", "", "", nil)
+		table.Rows = append(table.Rows, row)
+	}
+
+	for _, comment := range game.Comments {
+		row := append(append([]interface{}{}, gameColumns...),
+			comment.User,
+			comment.Message,
+			comment.DateCreated.Format(dateFormat),
+			comment.Like,
+		)
+		table.Rows = append(table.Rows, row)
+	}
+
+	return table
+}
+
+// reportTable flattens a report into one row per game. The report's leaders
+// are repeated on every row.
+func reportTable(report backend.Report) export.Table {
+	table := export.Table{
+		Columns: []string{
+			"title", "comments", "total_likes", "average_likes", "median_likes",
+			"min_likes", "max_likes", "std_dev_likes",
+			"user_with_most_comments", "highest_rated_game",
+		},
+		Rows: make([][]interface{}, 0, len(report.AverageLikesPerGame)),
+	}
+
+	for _, stats := range report.AverageLikesPerGame {
+		table.Rows = append(table.Rows, []interface{}{
+			stats.Title,
+			stats.Comments,
+			stats.TotalLikes,
+			stats.AverageLikes,
+			stats.MedianLikes,
+			stats.MinLikes,
+			stats.MaxLikes,
+			stats.StdDevLikes,
+			report.UserWithMostComments,
+			report.HighestRatedGame,
+		})
+	}
+
+	return table
+}
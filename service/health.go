@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/logging"
+)
+
+const (
+	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+)
+
+// healthCheckTimeout is how long a dependency has to respond to a readiness
+// check before it is reported as unavailable
+const healthCheckTimeout = 2 * time.Second
+
+// The status of the service or of a dependency in a health response.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Health is the response of the liveness and readiness endpoints. Checks is
+// only set for readiness.
+type Health struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyHealth `json:"checks,omitempty"`
+}
+
+// DependencyHealth is the result of checking a single dependency.
+type DependencyHealth struct {
+	Status  string  `json:"status"`
+	Latency float64 `json:"latency_seconds"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type healthCheck struct {
+	name    string
+	checker backend.HealthChecker
+}
+
+// AddHealthCheck adds a dependency checked by the readiness endpoint. The data
+// source is added as data_source by New if it is a backend.HealthChecker.
+func (s *Handler) AddHealthCheck(name string, checker backend.HealthChecker) {
+	s.healthChecks = append(s.healthChecks, healthCheck{name: name, checker: checker})
+}
+
+// livenessEndpoint reports that the process is serving requests. It doesn't
+// check any dependencies, so that a database outage doesn't restart the
+// service.
+func (s *Handler) livenessEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Health{Status: StatusOK})
+}
+
+// readinessEndpoint checks every dependency concurrently, responding with 503
+// Service Unavailable if any of them fail
+func (s *Handler) readinessEndpoint(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	health := Health{
+		Status: StatusOK,
+		Checks: make(map[string]DependencyHealth, len(s.healthChecks)),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, check := range s.healthChecks {
+		wg.Add(1)
+		go func(check healthCheck) {
+			defer wg.Done()
+			result := runHealthCheck(ctx, check.checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			health.Checks[check.name] = result
+			if result.Status != StatusOK {
+				health.Status = StatusUnavailable
+				logging.FromContext(ctx).Warnf("Dependency %s is unavailable: %s", check.name, result.Error)
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	if health.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
+
+func runHealthCheck(ctx context.Context, checker backend.HealthChecker) DependencyHealth {
+	start := time.Now()
+	err := checker.CheckHealth(ctx)
+
+	result := DependencyHealth{
+		Status:  StatusOK,
+		Latency: time.Since(start).Seconds(),
+	}
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader is the header a request's id is read from and returned in
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest request id accepted from a client
+const maxRequestIDLength = 128
+
+// Middleware wraps a handler, returning a handler which does some work around
+// it
+type Middleware func(http.Handler) http.Handler
+
+// requestIDMiddleware gives every request an id, using the client's
+// X-Request-ID if it sent a usable one. The id is returned in the response
+// and attached to the request's logger, see logging.WithRequestID.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether a request id sent by a client is safe to log
+// and echo back. Ids are limited to printable ASCII without spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Requests are still served without a unique id, which only affects
+		// the logs
+		log.Warnf("Unable to generate request id: %v", err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// accessLogMiddleware writes a log entry for each request once it has been
+// served. Requests are logged by route template rather than path so that
+// entries for the same endpoint can be grouped.
+func accessLogMiddleware(router *mux.Router) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r)
+
+			logging.FromContext(r.Context()).WithFields(log.Fields{
+				"method":  r.Method,
+				"route":   RouteTemplate(router, r),
+				"path":    r.URL.Path,
+				"status":  rec.Status(),
+				"bytes":   rec.bytes,
+				"latency": time.Since(start).Seconds(),
+			}).Info("Request served")
+		})
+	}
+}
+
+// UnmatchedRoute is the route template of requests which don't match a route
+const UnmatchedRoute = "unmatched"
+
+// RouteTemplate returns the template of the route in router matching r, such
+// as /games/{id:[0-9]+}, or UnmatchedRoute
+func RouteTemplate(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if !router.Match(r, &match) || match.MatchErr != nil || match.Route == nil {
+		return UnmatchedRoute
+	}
+
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return UnmatchedRoute
+	}
+
+	return template
+}
+
+// responseRecorder records the status and size of a response as it is
+// written. It flushes through to the underlying writer so that streamed
+// responses still work.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client, if the underlying writer
+// supports it
+func (rec *responseRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Status returns the status sent, which is 200 if nothing was written
+func (rec *responseRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+
+	return rec.status
+}
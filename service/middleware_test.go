@@ -0,0 +1,101 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DHBosworth/technichalexercise/logging"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_requestID(t *testing.T) {
+	handler := New(dummyDataSource{}, nil)
+
+	tests := []struct {
+		name   string
+		sentID string
+		check  func(t *testing.T, id string)
+	}{
+		{
+			name:   "Propagated",
+			sentID: "abc-123",
+			check: func(t *testing.T, id string) {
+				assert.Equal(t, "abc-123", id, "The client's id should be used")
+			},
+		},
+		{
+			name: "Generated",
+			check: func(t *testing.T, id string) {
+				assert.Len(t, id, 32, "A random id should be generated")
+			},
+		},
+		{
+			name:   "Unsafe id replaced",
+			sentID: "abc 123\n",
+			check: func(t *testing.T, id string) {
+				assert.Len(t, id, 32, "Ids with spaces should be replaced")
+			},
+		},
+		{
+			name:   "Long id replaced",
+			sentID: strings.Repeat("a", maxRequestIDLength+1),
+			check: func(t *testing.T, id string) {
+				assert.Len(t, id, 32, "Long ids should be replaced")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/games/1", nil)
+			if tt.sentID != "" {
+				req.Header.Set(RequestIDHeader, tt.sentID)
+			}
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			tt.check(t, resp.Header().Get(RequestIDHeader))
+		})
+	}
+}
+
+func TestHandler_accessLog(t *testing.T) {
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	handler := New(dummyDataSource{}, nil)
+
+	var requestID string
+	handler.Wrap(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID = logging.RequestID(r.Context())
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/games/1", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := hook.LastEntry()
+	if entry == nil {
+		t.Fatal("Request should have been logged")
+	}
+
+	assert.Equal(t, log.InfoLevel, entry.Level)
+	assert.Equal(t, "abc-123", entry.Data["request_id"])
+	assert.Equal(t, http.MethodGet, entry.Data["method"])
+	assert.Equal(t, "/games/{id:[0-9]+}", entry.Data["route"])
+	assert.Equal(t, http.StatusOK, entry.Data["status"])
+	assert.Contains(t, entry.Data, "bytes")
+	assert.Contains(t, entry.Data, "latency")
+
+	assert.Equal(t, "", requestID, "Middleware added later should run before the request id is set")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	assert.Equal(t, UnmatchedRoute, hook.LastEntry().Data["route"])
+	assert.Equal(t, http.StatusNotFound, hook.LastEntry().Data["status"])
+}
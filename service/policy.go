@@ -0,0 +1,38 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/DHBosworth/technichalexercise/service/rbac"
+)
+
+// MetricsPath is where the server exposes its metrics
+const MetricsPath = "/metrics"
+
+// Policy is the role needed for each of the service's endpoints. Every route
+// registered with the service's router must have a rule, see
+// rbac.Policy.Check, so new endpoints must be added here.
+var Policy = rbac.Policy{
+	{Route: gamesEnpointPath + "/trending", Methods: []string{http.MethodGet}, Role: rbac.Reader},
+	{Route: GameRoutes, Methods: []string{http.MethodGet}, Role: rbac.Reader},
+	{Route: GameRoutes + "/stats", Methods: []string{http.MethodGet}, Role: rbac.Reader},
+	{Route: GameRoutes + "/similar", Methods: []string{http.MethodGet}, Role: rbac.Reader},
+
+	{Route: ReportRoutes, Methods: []string{http.MethodGet}, Role: rbac.Reader},
+	{Route: ReportRoutes + "/sections", Methods: []string{http.MethodGet}, Role: rbac.Reader},
+	{Route: ReportRoutes + "/history", Methods: []string{http.MethodGet}, Role: rbac.Reader},
+	{Route: ReportRoutes + "/diff", Methods: []string{http.MethodGet}, Role: rbac.Reader},
+	{Route: ReportRoutes + "/timeseries", Methods: []string{http.MethodGet}, Role: rbac.Reader},
+
+	// Report jobs use the data source for a long time, so only editors can
+	// start and cancel them
+	{Route: ReportRoutes + "/jobs", Methods: []string{http.MethodPost}, Role: rbac.Editor},
+	{Route: ReportJobRoutes, Methods: []string{http.MethodGet}, Role: rbac.Reader},
+	{Route: ReportJobRoutes, Methods: []string{http.MethodDelete}, Role: rbac.Editor},
+	{Route: ReportRoutes + "/jobs/{id:[0-9a-f]+}/result", Methods: []string{http.MethodGet}, Role: rbac.Reader},
+
+	// Health checks are made by orchestrators which don't authenticate
+	{Route: healthzPath, Methods: []string{http.MethodGet}, Role: rbac.Anyone},
+	{Route: readyzPath, Methods: []string{http.MethodGet}, Role: rbac.Anyone},
+	{Route: MetricsPath, Methods: []string{http.MethodGet}, Role: rbac.Admin},
+}
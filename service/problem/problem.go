@@ -0,0 +1,97 @@
+// Package problem writes error responses in the application/problem+json
+// format of RFC 7807. As well as the standard members every problem has a
+// machine readable code, so clients can handle errors without parsing the
+// detail message. The type of a problem is a URN made from its code.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// TypePrefix is prepended to a problem's code to make its type
+const TypePrefix = "urn:technichalexercise:problem:"
+
+// The codes of the problems the service responds with.
+const (
+	CodeInvalidParameter   = "invalid_parameter"
+	CodeMissingID          = "missing_id"
+	CodeGameNotFound       = "game_not_found"
+	CodeEndpointNotFound   = "endpoint_not_found"
+	CodeMethodNotAllowed   = "method_not_allowed"
+	CodeDataSourceError    = "data_source_error"
+	CodeHistoryUnavailable = "history_unavailable"
+	CodeSnapshotNotFound   = "snapshot_not_found"
+	CodeJobNotFound        = "job_not_found"
+	CodeJobNotFinished     = "job_not_finished"
+	CodeTooManyJobs        = "too_many_jobs"
+	CodeRateLimited        = "rate_limited"
+	CodeUnauthenticated    = "unauthenticated"
+	CodeForbidden          = "forbidden"
+)
+
+// titles are the summaries of each problem code, which stay the same for
+// every occurrence of the problem
+var titles = map[string]string{
+	CodeInvalidParameter:   "Invalid request parameter",
+	CodeMissingID:          "Missing game id",
+	CodeGameNotFound:       "Game not found",
+	CodeEndpointNotFound:   "Endpoint not found",
+	CodeMethodNotAllowed:   "Method not allowed",
+	CodeDataSourceError:    "Data source error",
+	CodeHistoryUnavailable: "Report history unavailable",
+	CodeSnapshotNotFound:   "Report snapshot not found",
+	CodeJobNotFound:        "Report job not found",
+	CodeJobNotFinished:     "Report job not finished",
+	CodeTooManyJobs:        "Too many report jobs",
+	CodeRateLimited:        "Too many requests",
+	CodeUnauthenticated:    "Authentication required",
+	CodeForbidden:          "Forbidden",
+}
+
+// Problem is the body of an error response. Detail explains this occurrence of
+// the problem and Instance is the URI of the request it occurred for.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// New creates a problem with the given status and code. Codes without a title
+// are given the text of the status instead.
+func New(status int, code, detail string) Problem {
+	title, ok := titles[code]
+	if !ok {
+		title = http.StatusText(status)
+	}
+
+	return Problem{
+		Type:   TypePrefix + code,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write responds to r with p, filling in its instance from r
+func Write(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.RequestURI()
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Error responds to r with a new problem, see New
+func Error(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	Write(w, r, New(status, code, detail))
+}
@@ -0,0 +1,35 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestError(t *testing.T) {
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/games/3?top=5", nil)
+	Error(resp, req, http.StatusNotFound, CodeGameNotFound, "Game 3 not found")
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, ContentType, resp.Header().Get("Content-Type"))
+
+	var got Problem
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	assert.Equal(t, Problem{
+		Type:     "urn:technichalexercise:problem:game_not_found",
+		Title:    "Game not found",
+		Status:   http.StatusNotFound,
+		Detail:   "Game 3 not found",
+		Instance: "/games/3?top=5",
+		Code:     CodeGameNotFound,
+	}, got)
+}
+
+func TestNew_unknownCode(t *testing.T) {
+	p := New(http.StatusTeapot, "teapot", "")
+	assert.Equal(t, http.StatusText(http.StatusTeapot), p.Title, "Unknown codes should use the status text")
+}
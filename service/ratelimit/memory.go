@@ -0,0 +1,99 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often full buckets are removed from a MemoryStore. A
+// full bucket is the same as a missing one, so nothing is lost.
+const sweepInterval = time.Minute
+
+// MemoryStore implements Store for a single instance of the service.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take takes a token from the bucket for key
+func (store *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if now.Sub(store.lastSweep) >= sweepInterval {
+		store.sweep(now)
+	}
+
+	b, ok := store.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), last: now}
+		store.buckets[key] = b
+	}
+
+	return b.take(limit, now), nil
+}
+
+// sweep removes buckets which have filled up since they were last used.
+// store.mu must be held.
+func (store *MemoryStore) sweep(now time.Time) {
+	for key, b := range store.buckets {
+		if b.fill(b.limit, now) >= float64(b.limit.Requests) {
+			delete(store.buckets, key)
+		}
+	}
+
+	store.lastSweep = now
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// fill returns the tokens in the bucket at time now
+func (b *bucket) fill(limit Limit, now time.Time) float64 {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return b.tokens
+	}
+
+	return math.Min(float64(limit.Requests), b.tokens+elapsed*limit.rate())
+}
+
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	b.tokens = b.fill(limit, now)
+	if now.After(b.last) {
+		b.last = now
+	}
+	b.limit = limit
+
+	result := Result{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = tokenTime(1-b.tokens, limit)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = tokenTime(float64(limit.Requests)-b.tokens, limit)
+
+	return result
+}
+
+// tokenTime is how long it takes for the given number of tokens to be added
+// to a bucket
+func tokenTime(tokens float64, limit Limit) time.Duration {
+	return time.Duration(tokens / limit.rate() * float64(time.Second))
+}
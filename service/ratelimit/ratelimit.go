@@ -0,0 +1,229 @@
+// Package ratelimit limits how often each client can call the service's
+// endpoints using token buckets. Buckets are kept in a Store, which is in
+// memory by default but can be shared between instances of the service.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/auth"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/gorilla/mux"
+)
+
+// The headers describing a client's limit, sent with every limited response.
+const (
+	LimitHeader     = "RateLimit-Limit"
+	RemainingHeader = "RateLimit-Remaining"
+	ResetHeader     = "RateLimit-Reset"
+)
+
+// Limit allows a client Requests requests every Per, with up to Requests
+// made at once. Unused requests build up at an even rate.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit parses limits of the form requests/duration, such as 10/1m. The
+// duration's number may be left out, so 10/m is the same as 10/1m.
+func ParseLimit(s string) (Limit, error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("Invalid rate limit %q, should be requests/duration", s)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("Invalid rate limit %q, requests should be a positive integer", s)
+	}
+
+	per := parts[1]
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	duration, err := time.ParseDuration(per)
+	if err != nil || duration <= 0 {
+		return Limit{}, fmt.Errorf("Invalid rate limit %q, should be requests/duration", s)
+	}
+
+	return Limit{Requests: requests, Per: duration}, nil
+}
+
+func (limit Limit) String() string {
+	return strconv.Itoa(limit.Requests) + "/" + limit.Per.String()
+}
+
+// rate is the number of tokens added to a bucket each second
+func (limit Limit) rate() float64 {
+	return float64(limit.Requests) / limit.Per.Seconds()
+}
+
+// Rule limits the requests to a group of routes. Each client has a separate
+// bucket for each rule.
+type Rule struct {
+	// Name identifies the rule's buckets in the store
+	Name string
+	// RoutePrefix is the route template the rule applies to, including the
+	// routes below it, such as /games/{id:[0-9]+} for a game and its stats
+	RoutePrefix string
+	// Exclude are route templates below RoutePrefix, again including the
+	// routes below them, which aren't limited, such as cheap routes clients
+	// are expected to poll
+	Exclude []string
+	Limit   Limit
+}
+
+func (rule Rule) matches(route string) bool {
+	if !underRoute(route, rule.RoutePrefix) {
+		return false
+	}
+
+	for _, excluded := range rule.Exclude {
+		if underRoute(route, excluded) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// underRoute reports whether route is prefix or one of the routes below it
+func underRoute(route, prefix string) bool {
+	return route == prefix || strings.HasPrefix(route, prefix+"/")
+}
+
+// KeyFunc identifies the client making a request
+type KeyFunc func(r *http.Request) string
+
+// ClientKey identifies clients by the principal they authenticated as, see
+// auth.FromContext, and otherwise by their IP address. API keys are only used
+// once auth has validated them, so clients can't get a new limit by sending
+// made up keys.
+func ClientKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		// API key names and token subjects could be the same
+		return "principal:" + principal.Method + ":" + principal.Subject
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
+
+// Limiter is middleware which rejects requests with 429 Too Many Requests
+// once a client has used up its limit.
+type Limiter struct {
+	store Store
+	rules []Rule
+	key   KeyFunc
+	now   func() time.Time
+}
+
+// New creates a limiter applying rules, using the first which matches a
+// request's route. Clients are identified with ClientKey.
+func New(store Store, rules ...Rule) *Limiter {
+	return &Limiter{
+		store: store,
+		rules: rules,
+		key:   ClientKey,
+		now:   time.Now,
+	}
+}
+
+// SetKeyFunc changes how clients are identified
+func (limiter *Limiter) SetKeyFunc(key KeyFunc) {
+	limiter.key = key
+}
+
+// Middleware limits requests to routes matching a rule. It finds the route
+// with mux.CurrentRoute, so must be added to the router with Use.
+func (limiter *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rule, ok := limiter.rule(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := limiter.store.Take(r.Context(), rule.Name+":"+limiter.key(r), rule.Limit, limiter.now())
+		if err != nil {
+			// Requests are let through so that an unavailable store doesn't
+			// take the service down with it
+			logging.FromContext(r.Context()).Warnf("Unable to check rate limit: %v", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Set(LimitHeader, strconv.Itoa(rule.Limit.Requests))
+		header.Set(RemainingHeader, strconv.Itoa(result.Remaining))
+		header.Set(ResetHeader, seconds(result.Reset))
+
+		if !result.Allowed {
+			header.Set("Retry-After", seconds(result.RetryAfter))
+			problem.Error(w, r, http.StatusTooManyRequests, problem.CodeRateLimited,
+				fmt.Sprintf("Rate limit of %v exceeded, retry in %s seconds", rule.Limit, seconds(result.RetryAfter)))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (limiter *Limiter) rule(r *http.Request) (Rule, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return Rule{}, false
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return Rule{}, false
+	}
+
+	for _, rule := range limiter.rules {
+		if rule.matches(template) {
+			return rule, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// seconds formats d as a whole number of seconds, rounding up
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// Result is the state of a client's bucket after taking a token from it.
+type Result struct {
+	// Allowed is set if there was a token to take
+	Allowed bool
+	// Remaining is the number of whole tokens left
+	Remaining int
+	// RetryAfter is how long until a token is available, when not allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store represents any type which can hold the token buckets of each client.
+// Implementations shared by several instances of the service must take
+// tokens atomically.
+type Store interface {
+	// Take takes a token from the bucket for key at time now, filling it for
+	// the time since it was last used. New buckets start full.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
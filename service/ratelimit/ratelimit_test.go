@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DHBosworth/technichalexercise/service/auth"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Limit
+		wantErr bool
+	}{
+		{s: "10/1m", want: Limit{Requests: 10, Per: time.Minute}},
+		{s: "10/m", want: Limit{Requests: 10, Per: time.Minute}},
+		{s: "5/30s", want: Limit{Requests: 5, Per: 30 * time.Second}},
+		{s: "10", wantErr: true},
+		{s: "0/1s", wantErr: true},
+		{s: "ten/1s", wantErr: true},
+		{s: "10/0s", wantErr: true},
+		{s: "10/fortnight", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseLimit(tt.s)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Per: 2 * time.Second}
+	start := time.Now()
+	ctx := context.Background()
+
+	take := func(key string, at time.Duration) Result {
+		result, err := store.Take(ctx, key, limit, start.Add(at))
+		assert.NoError(t, err)
+		return result
+	}
+
+	assert.Equal(t, Result{Allowed: true, Remaining: 1, Reset: time.Second}, take("a", 0))
+	assert.Equal(t, Result{Allowed: true, Remaining: 0, Reset: 2 * time.Second}, take("a", 0))
+	assert.Equal(t, Result{RetryAfter: 500 * time.Millisecond, Reset: 1500 * time.Millisecond}, take("a", 500*time.Millisecond),
+		"An empty bucket should refill at an even rate")
+	assert.True(t, take("b", 500*time.Millisecond).Allowed, "Each key should have its own bucket")
+	assert.True(t, take("a", time.Second).Allowed)
+
+	take("a", time.Hour)
+	assert.Len(t, store.buckets, 1, "Full buckets should be swept away")
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	return Result{}, errors.New("Store unavailable")
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+	now := time.Now()
+	newRouter := func(store Store) *mux.Router {
+		limiter := New(store,
+			Rule{
+				Name:        "report",
+				RoutePrefix: "/games/report",
+				Exclude:     []string{"/games/report/jobs/{id}"},
+				Limit:       Limit{Requests: 1, Per: time.Minute},
+			},
+			Rule{Name: "game", RoutePrefix: "/games/{id:[0-9]+}", Limit: Limit{Requests: 2, Per: time.Second}},
+		)
+		limiter.now = func() time.Time { return now }
+
+		router := mux.NewRouter()
+		ok := func(w http.ResponseWriter, r *http.Request) {}
+		router.HandleFunc("/games/report", ok)
+		router.HandleFunc("/games/report/history", ok)
+		router.HandleFunc("/games/report/jobs/{id}", ok)
+		router.HandleFunc("/games/report/jobs/{id}/result", ok)
+		router.HandleFunc("/games/{id:[0-9]+}", ok)
+		router.HandleFunc("/games/{id:[0-9]+}/stats", ok)
+		router.HandleFunc("/games/trending", ok)
+		router.Use(limiter.Middleware)
+		return router
+	}
+
+	// keyName is the name of the API key the client authenticated with, as
+	// set by the auth middleware
+	serve := func(router *mux.Router, path, remoteAddr, keyName string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		if keyName != "" {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Subject: keyName, Method: auth.MethodAPIKey}))
+		}
+
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("Report limit", func(t *testing.T) {
+		router := newRouter(NewMemoryStore())
+
+		resp := serve(router, "/games/report", "10.0.0.1:1234", "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "1", resp.Header().Get(LimitHeader))
+		assert.Equal(t, "0", resp.Header().Get(RemainingHeader))
+		assert.Equal(t, "60", resp.Header().Get(ResetHeader))
+
+		resp = serve(router, "/games/report/history", "10.0.0.1:5678", "")
+		assert.Equal(t, http.StatusTooManyRequests, resp.Code, "Routes below the prefix should share the limit")
+		assert.Equal(t, "60", resp.Header().Get("Retry-After"))
+
+		var body problem.Problem
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, problem.CodeRateLimited, body.Code)
+
+		assert.Equal(t, http.StatusOK, serve(router, "/games/report", "10.0.0.2:1234", "").Code,
+			"Other addresses should have their own limit")
+		assert.Equal(t, http.StatusOK, serve(router, "/games/report", "10.0.0.1:1234", "reporting").Code,
+			"Clients with an API key should be limited by key")
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, "/games/report", "10.0.0.3:1234", "reporting").Code)
+	})
+
+	t.Run("Excluded routes", func(t *testing.T) {
+		router := newRouter(NewMemoryStore())
+
+		assert.Equal(t, http.StatusOK, serve(router, "/games/report", "10.0.0.1:1234", "").Code)
+		for i := 0; i < 5; i++ {
+			for _, path := range []string{"/games/report/jobs/abc", "/games/report/jobs/abc/result"} {
+				resp := serve(router, path, "10.0.0.1:1234", "")
+				assert.Equal(t, http.StatusOK, resp.Code, "Polling a job shouldn't be limited")
+				assert.Empty(t, resp.Header().Get(LimitHeader))
+			}
+		}
+	})
+
+	t.Run("Unauthenticated API keys", func(t *testing.T) {
+		router := newRouter(NewMemoryStore())
+
+		for i, key := range []string{"made-up", "another-made-up"} {
+			req := httptest.NewRequest(http.MethodGet, "/games/report", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set(auth.APIKeyHeader, key)
+
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+			if i == 0 {
+				assert.Equal(t, http.StatusOK, resp.Code)
+			} else {
+				assert.Equal(t, http.StatusTooManyRequests, resp.Code, "Rotating API keys shouldn't reset the limit")
+			}
+		}
+	})
+
+	t.Run("Separate limits", func(t *testing.T) {
+		router := newRouter(NewMemoryStore())
+
+		assert.Equal(t, http.StatusOK, serve(router, "/games/report", "10.0.0.1:1", "").Code)
+		assert.Equal(t, http.StatusOK, serve(router, "/games/1", "10.0.0.1:1", "").Code)
+		assert.Equal(t, http.StatusOK, serve(router, "/games/2/stats", "10.0.0.1:1", "").Code)
+		assert.Equal(t, http.StatusTooManyRequests, serve(router, "/games/1", "10.0.0.1:1", "").Code)
+
+		resp := serve(router, "/games/trending", "10.0.0.1:1", "")
+		assert.Equal(t, http.StatusOK, resp.Code, "Routes without a rule shouldn't be limited")
+		assert.Empty(t, resp.Header().Get(LimitHeader))
+	})
+
+	t.Run("Store unavailable", func(t *testing.T) {
+		router := newRouter(failingStore{})
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, serve(router, "/games/report", "10.0.0.1:1", "").Code)
+		}
+	})
+}
+
+func TestClientKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "ip:10.0.0.1", ClientKey(req))
+
+	for _, key := range []string{"first", "second"} {
+		req.Header.Set(auth.APIKeyHeader, key)
+		assert.Equal(t, "ip:10.0.0.1", ClientKey(req), "Unauthenticated API keys shouldn't give clients a new limit")
+	}
+
+	apiKey := req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Subject: "reporting", Method: auth.MethodAPIKey}))
+	assert.Equal(t, "principal:api_key:reporting", ClientKey(apiKey))
+
+	token := req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{Subject: "reporting", Method: auth.MethodJWT}))
+	assert.Equal(t, "principal:jwt:reporting", ClientKey(token))
+}
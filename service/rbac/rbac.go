@@ -0,0 +1,224 @@
+// Package rbac authorises requests by the role of the principal making them.
+// A Policy declares the role each route and method requires, and
+// Policy.Check makes sure no route on a router is left without one.
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/auth"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/gorilla/mux"
+)
+
+// Role is the level of access a principal has. Each role has the access of
+// the roles before it, so an admin can do anything an editor can.
+type Role int
+
+// The roles, in increasing order of access.
+const (
+	// Anyone is only used by policies, for routes which don't need a role,
+	// such as health checks
+	Anyone Role = iota
+	Reader
+	Editor
+	Moderator
+	Admin
+)
+
+var roleNames = map[Role]string{
+	Anyone:    "anyone",
+	Reader:    "reader",
+	Editor:    "editor",
+	Moderator: "moderator",
+	Admin:     "admin",
+}
+
+func (role Role) String() string {
+	if name, ok := roleNames[role]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("Role(%d)", int(role))
+}
+
+// ParseRole parses the name of a role which can be granted, so not Anyone
+func ParseRole(s string) (Role, error) {
+	for role, name := range roleNames {
+		if role != Anyone && strings.EqualFold(s, name) {
+			return role, nil
+		}
+	}
+
+	return Anyone, fmt.Errorf("Unknown role %q, should be reader, editor, moderator or admin", s)
+}
+
+// Rule requires Role for requests with one of Methods to the route with the
+// template Route.
+type Rule struct {
+	Route   string
+	Methods []string
+	Role    Role
+}
+
+// Policy is the set of rules for a router's routes.
+type Policy []Rule
+
+// Role returns the role needed to make a request with method to the route
+// with the given template
+func (policy Policy) Role(method, route string) (Role, bool) {
+	for _, rule := range policy {
+		if rule.Route != route {
+			continue
+		}
+
+		for _, m := range rule.Methods {
+			if m == method {
+				return rule.Role, true
+			}
+		}
+	}
+
+	return Anyone, false
+}
+
+// Check returns an error naming every route and method registered with
+// router, including those of its subrouters, which the policy has no rule for.
+// Routes without a handler, such as the path prefixes of subrouters, are
+// skipped.
+func (policy Policy) Check(router *mux.Router) error {
+	var missing []string
+	err := router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if route.GetHandler() == nil {
+			return nil
+		}
+
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Routes matching any method can't be given a rule for each
+			missing = append(missing, "* "+template)
+			return nil
+		}
+
+		for _, method := range methods {
+			if _, ok := policy.Role(method, template); !ok {
+				missing = append(missing, method+" "+template)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("No access policy for %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+var errNoRule = errors.New("No access policy for route")
+
+// Enforcer is middleware which rejects requests from principals without the
+// role their route needs. Requests to routes without a rule are rejected, so
+// a route missed by Policy.Check is closed rather than open.
+type Enforcer struct {
+	policy    Policy
+	roles     map[string]Role
+	anonymous Role
+}
+
+// NewEnforcer creates an enforcer for policy. Principals are granted the
+// role given to their subject by roles and the roles in their token, taking
+// the highest. Clients who haven't authenticated, and principals without a
+// role, have the anonymous role, where Anyone means they only have access to
+// routes which don't need a role.
+func NewEnforcer(policy Policy, roles map[string]Role, anonymous Role) *Enforcer {
+	return &Enforcer{
+		policy:    policy,
+		roles:     roles,
+		anonymous: anonymous,
+	}
+}
+
+// Role returns the highest role the principal on the request's context has
+func (enforcer *Enforcer) Role(r *http.Request) Role {
+	role := enforcer.anonymous
+
+	principal, ok := auth.FromContext(r.Context())
+	if !ok {
+		return role
+	}
+
+	if granted, ok := enforcer.roles[principal.Subject]; ok && granted > role {
+		role = granted
+	}
+	for _, name := range principal.Roles {
+		// Roles this service doesn't know about may be meant for others
+		if granted, err := ParseRole(name); err == nil && granted > role {
+			role = granted
+		}
+	}
+
+	return role
+}
+
+// Middleware enforces the policy. It finds the route with mux.CurrentRoute,
+// so must be added to the router with Use, after the auth middleware.
+func (enforcer *Enforcer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required, err := enforcer.required(r)
+		if err != nil {
+			logging.FromContext(r.Context()).Errorf("Denying %s %s: %v", r.Method, r.URL.Path, err)
+			problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden, "No access policy for this endpoint")
+			return
+		}
+
+		role := enforcer.Role(r)
+		if role >= required {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if _, ok := auth.FromContext(r.Context()); !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			problem.Error(w, r, http.StatusUnauthorized, problem.CodeUnauthenticated,
+				fmt.Sprintf("%s %s requires the %v role", r.Method, r.URL.Path, required))
+			return
+		}
+
+		problem.Error(w, r, http.StatusForbidden, problem.CodeForbidden,
+			fmt.Sprintf("%s %s requires the %v role", r.Method, r.URL.Path, required))
+	})
+}
+
+func (enforcer *Enforcer) required(r *http.Request) (Role, error) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return Anyone, errNoRule
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return Anyone, err
+	}
+
+	role, ok := enforcer.policy.Role(r.Method, template)
+	if !ok {
+		return Anyone, errNoRule
+	}
+
+	return role, nil
+}
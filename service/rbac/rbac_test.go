@@ -0,0 +1,167 @@
+package rbac
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DHBosworth/technichalexercise/service/auth"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var testPolicy = Policy{
+	{Route: "/healthz", Methods: []string{http.MethodGet}, Role: Anyone},
+	{Route: "/games/{id:[0-9]+}", Methods: []string{http.MethodGet}, Role: Reader},
+	{Route: "/games/{id:[0-9]+}", Methods: []string{http.MethodPut, http.MethodDelete}, Role: Editor},
+	{Route: "/games/{id:[0-9]+}/comments", Methods: []string{http.MethodDelete}, Role: Moderator},
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {}
+
+func testRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/healthz", ok).Methods(http.MethodGet)
+
+	games := router.PathPrefix("/games").Subrouter()
+	games.HandleFunc("/{id:[0-9]+}", ok).Methods(http.MethodGet, http.MethodPut, http.MethodDelete)
+	games.HandleFunc("/{id:[0-9]+}/comments", ok).Methods(http.MethodDelete)
+
+	return router
+}
+
+func TestParseRole(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Role
+		wantErr bool
+	}{
+		{name: "reader", want: Reader},
+		{name: "Editor", want: Editor},
+		{name: "moderator", want: Moderator},
+		{name: "admin", want: Admin},
+		{name: "anyone", wantErr: true},
+		{name: "owner", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRole(tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	assert.NoError(t, testPolicy.Check(testRouter()))
+
+	router := testRouter()
+	router.HandleFunc("/games/{id:[0-9]+}/comments", ok).Methods(http.MethodPost)
+	router.HandleFunc("/metrics", ok)
+
+	err := testPolicy.Check(router)
+	if assert.Error(t, err) {
+		assert.Equal(t, "No access policy for * /metrics, POST /games/{id:[0-9]+}/comments", err.Error())
+	}
+}
+
+func TestEnforcer_Middleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		principal  *auth.Principal
+		anonymous  Role
+		wantStatus int
+		wantCode   string
+	}{
+		{
+			name:       "Anonymous reader",
+			method:     http.MethodGet,
+			path:       "/games/1",
+			anonymous:  Reader,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Anonymous without a role",
+			method:     http.MethodGet,
+			path:       "/games/1",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   problem.CodeUnauthenticated,
+		},
+		{
+			name:       "Open to anyone",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Configured role",
+			method:     http.MethodPut,
+			path:       "/games/1",
+			principal:  &auth.Principal{Subject: "editor-key", Method: auth.MethodAPIKey},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Higher role",
+			method:     http.MethodDelete,
+			path:       "/games/1",
+			principal:  &auth.Principal{Subject: "alice", Method: auth.MethodJWT, Roles: []string{"other", "admin"}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Lower role",
+			method:     http.MethodDelete,
+			path:       "/games/1/comments",
+			principal:  &auth.Principal{Subject: "editor-key", Method: auth.MethodAPIKey},
+			wantStatus: http.StatusForbidden,
+			wantCode:   problem.CodeForbidden,
+		},
+		{
+			name:       "Principal without a role",
+			method:     http.MethodPut,
+			path:       "/games/1",
+			principal:  &auth.Principal{Subject: "bob", Method: auth.MethodJWT},
+			anonymous:  Reader,
+			wantStatus: http.StatusForbidden,
+			wantCode:   problem.CodeForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enforcer := NewEnforcer(testPolicy, map[string]Role{"editor-key": Editor}, tt.anonymous)
+			router := testRouter()
+			router.Use(enforcer.Middleware)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tt.principal))
+			}
+
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, req)
+
+			assert.Equal(t, tt.wantStatus, resp.Code)
+			if tt.wantCode != "" {
+				assert.Contains(t, resp.Body.String(), `"code":"`+tt.wantCode+`"`)
+			}
+		})
+	}
+}
+
+func TestEnforcer_MissingRule(t *testing.T) {
+	enforcer := NewEnforcer(Policy{}, nil, Admin)
+	router := testRouter()
+	router.Use(enforcer.Middleware)
+
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusForbidden, resp.Code, "Routes without a rule should be closed")
+}
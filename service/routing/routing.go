@@ -0,0 +1,86 @@
+// Package routing provides the responses shared by the service's routers for
+// requests which don't match any of their routes.
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/DHBosworth/technichalexercise/logging"
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/gorilla/mux"
+)
+
+// SetErrorHandlers makes router respond with a JSON error to requests for
+// unknown paths and a 405 naming the allowed methods in the Allow header to
+// requests using the wrong method. The allowed methods are worked out from the
+// routes registered with router when the request is made, so routes may be
+// added after the handlers are set.
+func SetErrorHandlers(router *mux.Router) {
+	router.NotFoundHandler = http.HandlerFunc(notFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methodNotAllowed(w, r, AllowedMethods(router, r))
+	})
+}
+
+// AllowedMethods lists the methods which router has a route for at the path
+// requested by r, in alphabetical order. Only methods used by at least one
+// of its routes are tried.
+func AllowedMethods(router *mux.Router, r *http.Request) []string {
+	allowed := make([]string, 0)
+	for _, method := range routeMethods(router) {
+		req := r.Clone(r.Context())
+		req.Method = method
+
+		var match mux.RouteMatch
+		if router.Match(req, &match) && match.MatchErr == nil {
+			allowed = append(allowed, method)
+		}
+	}
+
+	return allowed
+}
+
+// routeMethods lists every method used by the routes of router, including
+// those of its subrouters
+func routeMethods(router *mux.Router) []string {
+	seen := make(map[string]bool)
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			// Routes without methods match any method, so have nothing to add
+			return nil
+		}
+
+		for _, method := range methods {
+			seen[method] = true
+		}
+		return nil
+	})
+
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	return methods
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	logging.FromContext(r.Context()).Debugf("No endpoint for %s %s", r.Method, r.URL.Path)
+
+	problem.Error(w, r, http.StatusNotFound, problem.CodeEndpointNotFound,
+		fmt.Sprintf("No endpoint at %s", r.URL.Path))
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed []string) {
+	logging.FromContext(r.Context()).Debugf("Method %s not allowed for %s", r.Method, r.URL.Path)
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	problem.Error(w, r, http.StatusMethodNotAllowed, problem.CodeMethodNotAllowed,
+		fmt.Sprintf("Method %s not allowed for %s, allowed methods are %s",
+			r.Method, r.URL.Path, strings.Join(allowed, ", ")))
+}
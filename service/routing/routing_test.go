@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DHBosworth/technichalexercise/service/problem"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRouter() *mux.Router {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	router := mux.NewRouter()
+	SetErrorHandlers(router)
+
+	sub := router.PathPrefix("/games").Subrouter()
+	SetErrorHandlers(sub)
+	sub.Path("/jobs").Methods(http.MethodPost).HandlerFunc(ok)
+	sub.Path("/jobs/{id}").Methods(http.MethodGet).HandlerFunc(ok)
+	sub.Path("/jobs/{id}").Methods(http.MethodDelete).HandlerFunc(ok)
+
+	return router
+}
+
+func TestSetErrorHandlers(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		name  string
+		req   *http.Request
+		check func(t *testing.T, resp *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Allowed method",
+			req:  httptest.NewRequest(http.MethodDelete, "/games/jobs/1", nil),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, resp.Code)
+			},
+		},
+		{
+			name: "Wrong method",
+			req:  httptest.NewRequest(http.MethodPut, "/games/jobs/1", nil),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+				assert.Equal(t, "DELETE, GET", resp.Header().Get("Allow"))
+				assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+			},
+		},
+		{
+			name: "Unknown path in subrouter",
+			req:  httptest.NewRequest(http.MethodGet, "/games/missing", nil),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, resp.Code)
+
+				var body problem.Problem
+				assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, problem.CodeEndpointNotFound, body.Code)
+				assert.Equal(t, "No endpoint at /games/missing", body.Detail)
+			},
+		},
+		{
+			name: "Unknown path",
+			req:  httptest.NewRequest(http.MethodGet, "/missing", nil),
+			check: func(t *testing.T, resp *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, resp.Code)
+				assert.Equal(t, problem.ContentType, resp.Header().Get("Content-Type"))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			router.ServeHTTP(resp, tt.req)
+			tt.check(t, resp)
+		})
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	router := newTestRouter()
+
+	req := httptest.NewRequest(http.MethodPatch, "/games/jobs", nil)
+	assert.Equal(t, []string{http.MethodPost}, AllowedMethods(router, req))
+}
@@ -1,17 +1,25 @@
 package service
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/DHBosworth/technichalexercise/backend"
 	"github.com/DHBosworth/technichalexercise/service/gameservice"
+	"github.com/DHBosworth/technichalexercise/service/routing"
 	"github.com/gorilla/mux"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// Handler represents the http.Handler for the service
+// Handler represents the http.Handler for the service. Requests pass through
+// the middleware added with Wrap before reaching the router.
 type Handler struct {
 	*mux.Router
-	dataSource backend.ServiceDataSource
+	dataSource   backend.ServiceDataSource
+	games        *gameservice.Handler
+	handler      http.Handler
+	healthChecks []healthCheck
 }
 
 // New creates a new service with the given data source
@@ -25,19 +33,93 @@ func New(ds backend.ServiceDataSource, router *mux.Router) *Handler {
 		Router:     router,
 	}
 
+	if checker, ok := ds.(backend.HealthChecker); ok {
+		s.AddHealthCheck("data_source", checker)
+	}
+
 	s.RegisterEndpoints()
+	s.Wrap(accessLogMiddleware(s.Router), requestIDMiddleware)
 
 	return s
 }
 
+// Wrap adds middleware around the handler, each wrapping those before it so
+// the last runs first
+func (s *Handler) Wrap(middleware ...Middleware) {
+	if s.handler == nil {
+		s.handler = s.Router
+	}
+
+	for _, m := range middleware {
+		s.handler = m(s.handler)
+	}
+}
+
+// ServeHTTP passes the request through the middleware to the router
+func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.handler == nil {
+		s.Router.ServeHTTP(w, r)
+		return
+	}
+
+	s.handler.ServeHTTP(w, r)
+}
+
+// CancelJobs cancels the report jobs running in the background, see
+// gameservice.Handler.CancelJobs
+func (s *Handler) CancelJobs() {
+	if s.games != nil {
+		s.games.CancelJobs()
+	}
+}
+
+// SetRequestTimeout changes how long requests wait for the data source, see
+// gameservice.Handler.SetRequestTimeout
+func (s *Handler) SetRequestTimeout(timeout time.Duration) {
+	if s.games != nil {
+		s.games.SetRequestTimeout(timeout)
+	}
+}
+
+// SetJobLimits changes how many report jobs can run at once and how long each
+// can run for, see gameservice.Handler.SetJobLimits
+func (s *Handler) SetJobLimits(max int, timeout time.Duration) {
+	if s.games != nil {
+		s.games.SetJobLimits(max, timeout)
+	}
+}
+
+// SetCacheControl changes the Cache-Control headers sent with games and
+// reports, see gameservice.Handler.SetCacheControl
+func (s *Handler) SetCacheControl(game, report string) {
+	if s.games != nil {
+		s.games.SetCacheControl(game, report)
+	}
+}
+
 const (
 	gamesEnpointPath = "/games"
 )
 
+// Route templates of groups of endpoints, each including the routes below it
+const (
+	ReportRoutes = gamesEnpointPath + "/report"
+	GameRoutes   = gamesEnpointPath + "/{id:[0-9]+}"
+	// ReportJobRoutes are the status and result of a report job, which
+	// clients poll
+	ReportJobRoutes = ReportRoutes + "/jobs/{id:[0-9a-f]+}"
+)
+
 // RegisterEndpoints registers the services endpoints with the router
 func (s *Handler) RegisterEndpoints() {
 	log.Debugf("Registering Games endpoint")
 
 	gamesRouter := s.PathPrefix(gamesEnpointPath).Subrouter()
-	gameservice.New(s.dataSource, gamesRouter) // Game service endpoints are registered here
+	s.games = gameservice.New(s.dataSource, gamesRouter) // Game service endpoints are registered here
+
+	log.Debugf("Registering health endpoints")
+	s.HandleFunc(healthzPath, s.livenessEndpoint).Methods(http.MethodGet)
+	s.HandleFunc(readyzPath, s.readinessEndpoint).Methods(http.MethodGet)
+
+	routing.SetErrorHandlers(s.Router)
 }
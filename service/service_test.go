@@ -1,23 +1,54 @@
 package service
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 
 	"github.com/DHBosworth/technichalexercise/backend"
+	"github.com/DHBosworth/technichalexercise/service/problem"
 	"github.com/gorilla/mux"
 )
 
 type dummyDataSource struct {
 }
 
-func (dummyDataSource) Game(id string) (game backend.Game, err error) {
+func (dummyDataSource) Game(ctx context.Context, id string) (game backend.Game, err error) {
 	return game, err
 }
 
-func (dummyDataSource) Report() (report backend.Report, err error) {
+func (dummyDataSource) Report(ctx context.Context) (report backend.Report, err error) {
 	return report, nil
 }
 
+func (dummyDataSource) StreamReport(ctx context.Context, row func(backend.GameAverageLikes) error) (report backend.Report, err error) {
+	return report, nil
+}
+
+func (dummyDataSource) ReportSections(ctx context.Context, names []string) (sections map[string]interface{}, err error) {
+	return sections, nil
+}
+
+func (dummyDataSource) GameStats(ctx context.Context, id string, top int) (stats backend.GameStats, err error) {
+	return stats, nil
+}
+
+func (dummyDataSource) TimeSeries(ctx context.Context, interval backend.Interval) (series backend.TimeSeries, err error) {
+	return series, nil
+}
+
+func (dummyDataSource) SimilarGames(ctx context.Context, id string, limit int) (similar []backend.SimilarGame, err error) {
+	return similar, nil
+}
+
+func (dummyDataSource) Trending(ctx context.Context, opts backend.TrendingOptions) (trending backend.Trending, err error) {
+	return trending, nil
+}
+
 func hasRoute(router *mux.Router, routeS string) bool {
 	routeExists := false
 
@@ -72,9 +103,37 @@ func TestHandler_RegsiterEndpoints(t *testing.T) {
 					t.Errorf("Get Game endpoint not registered")
 				}
 
+				if !hasRoute(handler.Router, "/games/{id:[0-9]+}/stats") {
+					t.Errorf("Game stats endpoint not registered")
+				}
+
 				if !hasRoute(handler.Router, "/games/report") {
 					t.Errorf("Report endpoint not registered")
 				}
+
+				if !hasRoute(handler.Router, "/games/report/history") {
+					t.Errorf("Report history endpoint not registered")
+				}
+
+				if !hasRoute(handler.Router, "/games/report/diff") {
+					t.Errorf("Report diff endpoint not registered")
+				}
+
+				if !hasRoute(handler.Router, "/games/report/timeseries") {
+					t.Errorf("Time series endpoint not registered")
+				}
+
+				if !hasRoute(handler.Router, "/games/{id:[0-9]+}/similar") {
+					t.Errorf("Similar games endpoint not registered")
+				}
+
+				if !hasRoute(handler.Router, "/games/trending") {
+					t.Errorf("Trending endpoint not registered")
+				}
+
+				if !hasRoute(handler.Router, "/healthz") || !hasRoute(handler.Router, "/readyz") {
+					t.Errorf("Health endpoints not registered")
+				}
 			},
 		},
 	}
@@ -85,3 +144,156 @@ func TestHandler_RegsiterEndpoints(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_unmatchedRequests(t *testing.T) {
+	handler := New(dummyDataSource{}, nil)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "Unknown path",
+			method:     http.MethodGet,
+			path:       "/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Unknown games path",
+			method:     http.MethodGet,
+			path:       "/games/unknown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "Wrong method",
+			method:     http.MethodPost,
+			path:       "/games/1",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  http.MethodGet,
+		},
+		{
+			name:       "Wrong method for job",
+			method:     http.MethodPut,
+			path:       "/games/report/jobs/ab12",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "DELETE, GET",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if resp.Code != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", resp.Code, tt.wantStatus)
+			}
+			if got := resp.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+
+			var body problem.Problem
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Status != tt.wantStatus {
+				t.Errorf("Response should be a problem, got %q", resp.Body.String())
+			}
+		})
+	}
+}
+
+type checkerFunc func(ctx context.Context) error
+
+func (fn checkerFunc) CheckHealth(ctx context.Context) error {
+	return fn(ctx)
+}
+
+type healthyDataSource struct {
+	dummyDataSource
+}
+
+func (healthyDataSource) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+func TestHandler_healthEndpoints(t *testing.T) {
+	healthy := checkerFunc(func(ctx context.Context) error { return nil })
+	failing := checkerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+
+	tests := []struct {
+		name       string
+		ds         backend.ServiceDataSource
+		checks     map[string]backend.HealthChecker
+		path       string
+		wantStatus int
+		want       Health
+	}{
+		{
+			name:       "Liveness ignores dependencies",
+			ds:         dummyDataSource{},
+			checks:     map[string]backend.HealthChecker{"mongo": failing},
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+			want:       Health{Status: StatusOK},
+		},
+		{
+			name:       "Data source is checked",
+			ds:         healthyDataSource{},
+			path:       "/readyz",
+			wantStatus: http.StatusOK,
+			want: Health{Status: StatusOK, Checks: map[string]DependencyHealth{
+				"data_source": {Status: StatusOK},
+			}},
+		},
+		{
+			name:       "Failing dependency",
+			ds:         dummyDataSource{},
+			checks:     map[string]backend.HealthChecker{"mongo": failing, "report_store": healthy},
+			path:       "/readyz",
+			wantStatus: http.StatusServiceUnavailable,
+			want: Health{Status: StatusUnavailable, Checks: map[string]DependencyHealth{
+				"mongo":        {Status: StatusUnavailable, Error: "connection refused"},
+				"report_store": {Status: StatusOK},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(tt.ds, nil)
+			for name, checker := range tt.checks {
+				handler.AddHealthCheck(name, checker)
+			}
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if resp.Code != tt.wantStatus {
+				t.Errorf("Status = %d, want %d", resp.Code, tt.wantStatus)
+			}
+
+			var got Health
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatalf("Unable to decode response: %v", err)
+			}
+			for name, check := range got.Checks {
+				if check.Latency < 0 {
+					t.Errorf("Check %s has negative latency", name)
+				}
+				check.Latency = 0
+				got.Checks[name] = check
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Health = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	handler := New(dummyDataSource{}, nil)
+	handler.Handle(MetricsPath, http.NotFoundHandler()).Methods(http.MethodGet)
+
+	if err := Policy.Check(handler.Router); err != nil {
+		t.Errorf("Policy.Check() = %v, every endpoint should have an access policy", err)
+	}
+}
@@ -0,0 +1,151 @@
+// Package tlsconfig builds the TLS configuration the server is served with,
+// reloading its certificate when the files it was read from change.
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CertReloader holds a certificate and key read from files, which are read
+// again when they change. It provides the certificate to tls.Config through
+// GetCertificate so that connections use the latest one.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader reads the PEM encoded certificate and key from the given
+// files
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	reloader := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if _, err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// GetCertificate returns the current certificate, for use as
+// tls.Config.GetCertificate
+func (reloader *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+
+	return reloader.cert, nil
+}
+
+// Reload reads the certificate and key again if either file has been modified
+// since they were last read, reporting whether they were. The current
+// certificate is kept if the new one can't be read.
+func (reloader *CertReloader) Reload() (bool, error) {
+	modTime, err := latestModTime(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	reloader.mu.RLock()
+	unchanged := reloader.cert != nil && !modTime.After(reloader.modTime)
+	reloader.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("Unable to load certificate: %v", err)
+	}
+
+	reloader.mu.Lock()
+	reloader.cert = &cert
+	reloader.modTime = modTime
+	reloader.mu.Unlock()
+
+	return true, nil
+}
+
+// Watch checks the files for changes every interval until ctx is done.
+// Failures are logged and the files checked again at the next interval, so a
+// certificate being replaced file by file is picked up once both are written.
+func (reloader *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := reloader.Reload()
+		switch {
+		case err != nil:
+			log.Warnf("Unable to reload TLS certificate: %v", err)
+		case reloaded:
+			log.Infof("Reloaded TLS certificate from %s", reloader.certFile)
+		}
+	}
+}
+
+func latestModTime(names ...string) (time.Time, error) {
+	var latest time.Time
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// Server creates the TLS configuration for the server, which negotiates
+// HTTP/2 with clients that support it. If clientCAFile is set clients must
+// present a certificate signed by one of the PEM encoded CAs in it.
+func Server(reloader *CertReloader, clientCAFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No certificates found in %s", clientCAFile)
+	}
+
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return config, nil
+}
@@ -0,0 +1,211 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type keyPair struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newKeyPair creates a certificate for 127.0.0.1 signed by parent, or a self
+// signed CA if parent is nil
+func newKeyPair(t *testing.T, name string, parent *keyPair) *keyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unable to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("Unable to create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	return &keyPair{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return dir
+}
+
+func writeFile(t *testing.T, name string, data []byte, modTime time.Time) {
+	if err := ioutil.WriteFile(name, data, 0600); err != nil {
+		t.Fatalf("Unable to write %s: %v", name, err)
+	}
+	if err := os.Chtimes(name, modTime, modTime); err != nil {
+		t.Fatalf("Unable to set time of %s: %v", name, err)
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := tempDir(t)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	ca := newKeyPair(t, "ca", nil)
+
+	first := newKeyPair(t, "first", ca)
+	start := time.Now().Add(-time.Minute)
+	writeFile(t, certFile, first.certPEM, start)
+	writeFile(t, keyFile, first.keyPEM, start)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Unable to create reloader: %v", err)
+	}
+
+	current := func() string {
+		cert, _ := reloader.GetCertificate(nil)
+		parsed, _ := x509.ParseCertificate(cert.Certificate[0])
+		return parsed.Subject.CommonName
+	}
+	assert.Equal(t, "first", current())
+
+	reloaded, err := reloader.Reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded, "Unchanged files shouldn't be reloaded")
+
+	second := newKeyPair(t, "second", ca)
+	writeFile(t, certFile, second.certPEM, start.Add(time.Second))
+	reloaded, err = reloader.Reload()
+	assert.Error(t, err, "A certificate without its key should fail to load")
+	assert.False(t, reloaded)
+	assert.Equal(t, "first", current(), "The old certificate should be kept until the new one loads")
+
+	writeFile(t, keyFile, second.keyPEM, start.Add(2*time.Second))
+	reloaded, err = reloader.Reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	assert.Equal(t, "second", current())
+}
+
+func TestServer(t *testing.T) {
+	dir := tempDir(t)
+	ca := newKeyPair(t, "ca", nil)
+	server := newKeyPair(t, "server", ca)
+	client := newKeyPair(t, "client", ca)
+	untrusted := newKeyPair(t, "untrusted", newKeyPair(t, "other ca", nil))
+
+	certFile, keyFile, caFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+	writeFile(t, certFile, server.certPEM, time.Now())
+	writeFile(t, keyFile, server.keyPEM, time.Now())
+	writeFile(t, caFile, ca.certPEM, time.Now())
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Unable to create reloader: %v", err)
+	}
+
+	_, err = Server(reloader, certFile+".missing")
+	assert.Error(t, err, "A missing CA bundle should be an error")
+
+	config, err := Server(reloader, caFile)
+	if err != nil {
+		t.Fatalf("Unable to create config: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unable to listen: %v", err)
+	}
+	srv := &http.Server{
+		TLSConfig: config,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+		}),
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := []struct {
+		name      string
+		client    *keyPair
+		wantErr   bool
+		wantProto int
+	}{
+		{name: "Trusted client", client: client, wantProto: 2},
+		{name: "No client certificate", wantErr: true},
+		{name: "Untrusted client", client: untrusted, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConfig := &tls.Config{RootCAs: roots}
+			if tt.client != nil {
+				cert, err := tls.X509KeyPair(tt.client.certPEM, tt.client.keyPEM)
+				if err != nil {
+					t.Fatalf("Unable to load client certificate: %v", err)
+				}
+				clientConfig.Certificates = []tls.Certificate{cert}
+			}
+
+			httpClient := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   clientConfig,
+				ForceAttemptHTTP2: true,
+			}}
+			resp, err := httpClient.Get("https://" + ln.Addr().String())
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+
+			body, _ := ioutil.ReadAll(resp.Body)
+			assert.Equal(t, tt.wantProto, resp.ProtoMajor, "HTTP/2 should be negotiated")
+			assert.Equal(t, tt.client.cert.Subject.CommonName, string(body))
+		})
+	}
+}